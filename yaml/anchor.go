@@ -0,0 +1,199 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DefaultAliasLimit is the number of nodes the aliases in a document may
+// expand to if Parser.AliasLimit is not set.
+const DefaultAliasLimit = 1000000
+
+// mergeKey is the key which merges the entries of other maps into a map.
+const mergeKey = "<<"
+
+// An anchor is a node which has been named with '&' so that it may be
+// repeated by an alias.
+type anchor struct {
+	node *note
+	size int  // the number of nodes the anchored node expands to
+	done bool // false while the anchored node is being read
+}
+
+// anchorName splits the name of an anchor or alias, which follows the '&'
+// or '*' at the start of text, from the rest of the text.  The name ends at
+// a space or a flow indicator.
+func anchorName(text []byte) (name string, rest []byte) {
+	end := bytes.IndexAny(text, " \t,[]{}")
+	if end < 0 {
+		end = len(text)
+	}
+	return string(text[1:end]), bytes.TrimLeft(text[end:], " ")
+}
+
+// resetAnchors forgets the anchors of the previous document.
+func (p *parser) resetAnchors() {
+	p.anchors = nil
+	p.aliases = nil
+	p.expanded = 0
+}
+
+// defineAnchor records that the node named by an anchor is being read, so
+// that an alias to it from within itself can be detected.
+func (p *parser) defineAnchor(name string) {
+	if p.anchors == nil {
+		p.anchors = make(map[string]*anchor)
+	}
+	p.anchors[name] = &anchor{}
+}
+
+// setAnchor records the node n describes, which has been read, as the node
+// named by an anchor.
+func (p *parser) setAnchor(name string, n *note) *note {
+	if n != nil {
+		n.Anchor = name
+	}
+	if p.anchors == nil {
+		p.defineAnchor(name)
+	}
+	p.anchors[name] = &anchor{node: n, size: p.size(n), done: true}
+	return n
+}
+
+// alias returns a note for the node an alias at the given column of line
+// refers to.  The alias shares the anchored node's Map, List or Scalar, but
+// has its own position.
+func (p *parser) alias(line *indentedLine, col int, name string) (*note, error) {
+	a, ok := p.anchors[name]
+	switch {
+	case name == "":
+		return nil, errorAt(line, col, SyntaxError, "missing alias name")
+	case !ok:
+		return nil, errorAt(line, col, AliasError, "unknown alias *%s", name)
+	case !a.done:
+		return nil, errorAt(line, col, AliasError, "alias *%s refers to a node which contains it", name)
+	}
+
+	if limit := p.aliasLimit; limit >= 0 {
+		if limit == 0 {
+			limit = DefaultAliasLimit
+		}
+		if p.expanded += a.size; p.expanded > limit {
+			return nil, errorAt(line, col, AliasError, "aliases expand to more than %d nodes", limit)
+		}
+	}
+
+	if a.node == nil {
+		return nil, nil
+	}
+	alias := *a.node
+	alias.Pos = line.pos(col)
+	alias.Anchor = ""
+	alias.Alias = name
+	alias.Comment = ""
+	alias.HeadComment = ""
+	alias.FootComment = ""
+	alias.layout = layout{}
+	if p.aliases == nil {
+		p.aliases = make(map[*note]int)
+	}
+	p.aliases[&alias] = a.size
+	return &alias, nil
+}
+
+// size returns the number of nodes in the tree described by n, counting each
+// alias as a copy of the node it refers to.
+func (p *parser) size(n *note) int {
+	if size, ok := p.aliases[n]; ok {
+		return size
+	}
+	size := 1
+	switch node := n.value().(type) {
+	case Map:
+		for key := range node {
+			size += p.size(n.entry(node, key))
+		}
+	case List:
+		for i := range node {
+			size += p.size(n.item(node, i))
+		}
+	}
+	return size
+}
+
+// setKey sets key in the map n describes to the node value describes, unless
+// key is the merge key, in which case the entries of value, which must be a
+// map or a list of maps, are added to the map unless it already has them.
+// The keys are added to the end of the map's order.  The merge key itself is
+// recorded in the order and the entries of n, but not in the map, so that it
+// can be written back.
+func setKey(n *note, key string, value *note) error {
+	m := n.node.(Map)
+	set := func(k string, v *note) {
+		if _, ok := m[k]; !ok {
+			n.Keys = append(n.Keys, k)
+		}
+		m[k] = v.value()
+		n.setEntry(k, v)
+	}
+
+	if key != mergeKey {
+		set(key, value)
+		return nil
+	}
+	n.Keys = append(n.Keys, mergeKey)
+	n.setEntry(mergeKey, value)
+
+	merge := func(from *note) bool {
+		src, ok := from.value().(Map)
+		if !ok {
+			return false
+		}
+		for _, k := range keysOf(src, from) {
+			if _, ok := m[k]; !ok {
+				set(k, mergedNote(src[k], from.entry(src, k)))
+			}
+		}
+		return true
+	}
+
+	if list, ok := value.value().(List); ok {
+		for i, item := range list {
+			if !merge(value.item(list, i)) {
+				return fmt.Errorf("cannot merge %s into a map", nodeKind(item))
+			}
+		}
+		return nil
+	}
+	if !merge(value) {
+		return fmt.Errorf("cannot merge %s into a map", nodeKind(value.value()))
+	}
+	return nil
+}
+
+// mergedNote returns a note for node, which a merge key copies into a map
+// from another, which describes it with n.
+func mergedNote(node Node, n *note) *note {
+	merged := note{node: node}
+	if n != nil {
+		merged = *n
+		merged.Anchor = ""
+		merged.HeadComment = ""
+	}
+	merged.merged = true
+	return &merged
+}
@@ -0,0 +1,246 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var anchorTests = []struct {
+	Input  string
+	Output string // the same document with the aliases expanded
+}{
+	{
+		Input: "a: &v hello\n" +
+			"b: *v\n",
+		Output: "a: hello\n" +
+			"b: hello\n",
+	},
+	{
+		Input: "- &x one\n" +
+			"- *x\n",
+		Output: "- one\n" +
+			"- one\n",
+	},
+	{
+		Input: "base: &base\n" +
+			"  host: db\n" +
+			"  port: 5432\n" +
+			"prod:\n" +
+			"  <<: *base\n" +
+			"  host: prod-db\n",
+		Output: "base:\n" +
+			"  host: db\n" +
+			"  port: 5432\n" +
+			"prod:\n" +
+			"  host: prod-db\n" +
+			"  port: 5432\n",
+	},
+	{
+		Input: "base: &base {host: db, port: 5432}\n" +
+			"prod:\n" +
+			"  host: prod-db\n" +
+			"  <<: *base\n",
+		Output: "base: {host: db, port: 5432}\n" +
+			"prod:\n" +
+			"  host: prod-db\n" +
+			"  port: 5432\n",
+	},
+	{
+		Input: "a: &a {x: 1, y: 1}\n" +
+			"b: &b {y: 2, z: 2}\n" +
+			"c:\n" +
+			"  <<: [*a, *b]\n" +
+			"  z: 3\n",
+		Output: "a: {x: 1, y: 1}\n" +
+			"b: {y: 2, z: 2}\n" +
+			"c:\n" +
+			"  x: 1\n" +
+			"  y: 1\n" +
+			"  z: 3\n",
+	},
+	{
+		Input: "list: &l [1, &two 2]\n" +
+			"copy: [*l, *two]\n",
+		Output: "list: [1, 2]\n" +
+			"copy: [[1, 2], 2]\n",
+	},
+	{
+		Input: "- &item\n" +
+			"  name: x\n" +
+			"- *item\n",
+		Output: "- name: x\n" +
+			"- name: x\n",
+	},
+}
+
+func TestAnchors(t *testing.T) {
+	for idx, test := range anchorTests {
+		f, err := new(Parser).ParseFile(bytes.NewBufferString(test.Input))
+		if err != nil {
+			t.Errorf("%d. parse(%q): %s", idx, test.Input, err)
+			continue
+		}
+		if got, want := f.Root, Config(test.Output).Root; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d. parse(%q) = %#v, want %#v", idx, test.Input, got, want)
+		}
+		if got, want := f.Render(), test.Input; got != want {
+			t.Errorf("%d. Render(%q):\n%s\nwant:\n%s", idx, test.Input, got, want)
+		}
+	}
+}
+
+func TestAliasSharesNode(t *testing.T) {
+	f, err := new(Parser).ParseFile(bytes.NewBufferString("a: &a\n  x: 1\nb: *a\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	a, _ := Child(f.Root, "a")
+	b, _ := Child(f.Root, "b")
+
+	if info, _ := f.Info("a"); info.Anchor != "a" {
+		t.Errorf("anchor = %q, want %q", info.Anchor, "a")
+	}
+	if info, _ := f.Info("b"); info.Alias != "a" {
+		t.Errorf("alias = %q, want %q", info.Alias, "a")
+	}
+	pos, err := f.Position("b")
+	if want := (Position{Line: 3, Column: 4}); err != nil || pos != want {
+		t.Errorf("alias position = %s, %v; want %s", pos, err, want)
+	}
+	ma, mb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if ma.Kind() != reflect.Map || ma.Pointer() != mb.Pointer() {
+		t.Errorf("alias %#v does not share the anchored map %#v", b, a)
+	}
+}
+
+var anchorErrorTests = []struct {
+	Input string
+	Kind  ErrorKind
+	Err   string
+}{
+	{
+		Input: "a: *nowhere\n",
+		Kind:  AliasError,
+		Err:   "yaml: 1:4: unknown alias *nowhere",
+	},
+	{
+		Input: "a: &x\n" +
+			"  b: *x\n",
+		Kind: AliasError,
+		Err:  "yaml: 2:6: alias *x refers to a node which contains it",
+	},
+	{
+		Input: "a: &x [1, *x]\n",
+		Kind:  AliasError,
+		Err:   "yaml: 1:11: alias *x refers to a node which contains it",
+	},
+	{
+		Input: "a: *x y\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:7: unexpected "y" after alias`,
+	},
+	{
+		Input: "a: & x\n",
+		Kind:  SyntaxError,
+		Err:   "yaml: 1:4: missing anchor name",
+	},
+	{
+		Input: "a: &x 1\n" +
+			"b:\n" +
+			"  <<: *x\n",
+		Kind: SyntaxError,
+		Err:  "yaml: 3:3: cannot merge a scalar into a map",
+	},
+	{
+		Input: "a: &x 1\n" +
+			"b: {<<: [*x]}\n",
+		Kind: SyntaxError,
+		Err:  "yaml: 2:13: cannot merge a scalar into a map",
+	},
+}
+
+func TestAnchorErrors(t *testing.T) {
+	for idx, test := range anchorErrorTests {
+		_, err := Parse(bytes.NewBufferString(test.Input))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%d. parse(%q) error = %#v, want a *ParseError", idx, test.Input, err)
+			continue
+		}
+		if got, want := perr.Error(), test.Err; got != want {
+			t.Errorf("%d. parse(%q) error:\n got %q\nwant %q", idx, test.Input, got, want)
+		}
+		if got, want := perr.Kind, test.Kind; got != want {
+			t.Errorf("%d. parse(%q) kind = %s, want %s", idx, test.Input, got, want)
+		}
+	}
+}
+
+// laughs returns a document in which each of n levels of anchors refers to
+// the one before it ten times.
+func laughs(n int) string {
+	var buf bytes.Buffer
+	buf.WriteString("l0: &l0 lol\n")
+	for i := 1; i <= n; i++ {
+		refs := strings.TrimSuffix(strings.Repeat(fmt.Sprintf("*l%d, ", i-1), 10), ", ")
+		fmt.Fprintf(&buf, "l%d: &l%d [%s]\n", i, i, refs)
+	}
+	return buf.String()
+}
+
+func TestAliasLimit(t *testing.T) {
+	tests := []struct {
+		Levels int
+		Limit  int
+		Err    string
+	}{
+		{Levels: 3, Limit: 0},
+		{Levels: 3, Limit: 2000},
+		{Levels: 3, Limit: 100, Err: "yaml: 3:50: aliases expand to more than 100 nodes"},
+		{Levels: 9, Limit: 0, Err: "yaml: 7:45: aliases expand to more than 1000000 nodes"},
+		{Levels: 5, Limit: -1},
+	}
+	for _, test := range tests {
+		p := &Parser{AliasLimit: test.Limit}
+		_, err := p.Parse(bytes.NewBufferString(laughs(test.Levels)))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if want := test.Err; got != want {
+			t.Errorf("%d levels, limit %d: error = %q, want %q", test.Levels, test.Limit, got, want)
+		}
+	}
+}
+
+func TestAnchorsPerDocument(t *testing.T) {
+	d := NewDecoder(bytes.NewBufferString("a: &x 1\n---\nb: *x\n"))
+	if _, err := d.Next(); err != nil {
+		t.Fatalf("first document: %s", err)
+	}
+	_, err := d.Next()
+	if got, want := fmt.Sprint(err), "yaml: 3:4: unknown alias *x"; got != want {
+		t.Errorf("second document error = %q, want %q", got, want)
+	}
+	if _, err := d.Next(); err == io.EOF {
+		t.Errorf("Next after an error returned io.EOF")
+	}
+}
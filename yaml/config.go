@@ -17,16 +17,25 @@ package yaml
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // A File represents the top-level YAML node found in a file.  It is intended
 // for use as a configuration file.
+//
+// A File read by the parser also records how each of its nodes was written:
+// its position, style, comments, tag and anchor, which Render uses to write
+// the file back as it was.  See Info.
 type File struct {
 	Root Node
 
+	notes  *note  // describes Root, if it was parsed or marshaled
+	schema Schema // the schema by which plain scalars resolve
+
 	// TODO(kevlar): Add a cache?
 }
 
@@ -38,24 +47,16 @@ func ReadFile(filename string) (*File, error) {
 	}
 	defer fin.Close()
 
-	f := new(File)
-	f.Root, err = Parse(fin)
-	if err != nil {
-		return nil, err
-	}
-
-	return f, nil
+	return (&Parser{Filename: filename}).ParseFile(fin)
 }
 
 // Config reads a YAML configuration from a static string.  If an error is
 // found, it will panic.  This is a utility function and is intended for use in
 // initializers.
 func Config(yamlconf string) *File {
-	var err error
 	buf := bytes.NewBufferString(yamlconf)
 
-	f := new(File)
-	f.Root, err = Parse(buf)
+	f, err := new(Parser).ParseFile(buf)
 	if err != nil {
 		panic(err)
 	}
@@ -78,21 +79,48 @@ func ConfigFile(filename string) *File {
 // format as that expected by Child.  If the final node is not a Scalar, Get
 // will return an error.
 func (f *File) Get(spec string) (string, error) {
-	node, err := Child(f.Root, spec)
+	s, _, err := f.scalar(spec)
 	if err != nil {
 		return "", err
 	}
+	return s.String(), nil
+}
+
+// lookup retrieves the node specified by spec, along with the note which
+// describes it.
+func (f *File) lookup(spec string) (Node, *note, error) {
+	p, err := CompilePath(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.find(f.Root, f.notes.of(f.Root))
+}
+
+// scalar retrieves the Scalar specified by spec, along with the note which
+// describes it.
+func (f *File) scalar(spec string) (Scalar, *note, error) {
+	node, n, err := f.lookup(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := scalarAt(node, spec); err != nil {
+		return "", nil, err
+	}
+	return node.(Scalar), n, nil
+}
 
+// scalarAt returns an error unless node, which was found at spec, is a
+// Scalar.
+func scalarAt(node Node, spec string) error {
 	if node == nil {
-		return "", &NodeNotFound{
+		return &NodeNotFound{
 			Full: spec,
 			Spec: spec,
 		}
 	}
 
-	scalar, ok := node.(Scalar)
-	if !ok {
-		return "", &NodeTypeMismatch{
+	if _, ok := node.(Scalar); !ok {
+		return &NodeTypeMismatch{
 			Full:     spec,
 			Spec:     spec,
 			Token:    "$",
@@ -100,35 +128,476 @@ func (f *File) Get(spec string) (string, error) {
 			Node:     node,
 		}
 	}
-	return scalar.String(), nil
+	return nil
 }
 
-func (f *File) GetInt(spec string) (int64, error) {
-	s, err := f.Get(spec)
+// resolve retrieves the value of the scalar specified by spec, as returned
+// by Resolve, which must be of one of the given kinds.  A quoted scalar, or
+// one tagged "!!str", is a string, so it is not converted to another kind.
+func (f *File) resolve(spec string, kinds ...string) (interface{}, error) {
+	s, n, err := f.scalar(spec)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	got, value, err := resolve(s, n, f.schema)
+	if err != nil {
+		return nil, fmt.Errorf("yaml: %s: %s", spec, err)
+	}
+	for _, kind := range kinds {
+		if got == kind {
+			return value, nil
+		}
+	}
+	return nil, &NodeTypeMismatch{
+		Full:     spec,
+		Spec:     spec,
+		Token:    "$",
+		Expected: "yaml.Scalar holding " + strings.Join(kinds, " or "),
+		Node:     s,
 	}
+}
 
-	i, err := strconv.ParseInt(s, 10, 64)
+// GetInt retrieves an integer from the file specified by a string of the
+// same format as that expected by Child.  The scalar must be a plain integer,
+// as resolved by Resolve, which fits in an int64.
+func (f *File) GetInt(spec string) (int64, error) {
+	value, err := f.resolve(spec, IntTag)
 	if err != nil {
 		return 0, err
 	}
 
+	i, ok := value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("yaml: %s: %d overflows int64", spec, value)
+	}
 	return i, nil
 }
 
+// GetBool retrieves a boolean from the file specified by a string of the
+// same format as that expected by Child.  The scalar must be a plain
+// boolean, as resolved by Resolve.
 func (f *File) GetBool(spec string) (bool, error) {
-	s, err := f.Get(spec)
+	value, err := f.resolve(spec, BoolTag)
 	if err != nil {
 		return false, err
 	}
+	return value.(bool), nil
+}
 
-	b, err := strconv.ParseBool(s)
+// GetFloat retrieves a float from the file specified by a string of the same
+// format as that expected by Child.  The scalar must be a plain float or
+// integer, as resolved by Resolve.
+func (f *File) GetFloat(spec string) (float64, error) {
+	value, err := f.resolve(spec, FloatTag, IntTag)
 	if err != nil {
-		return false, err
+		return 0, err
+	}
+
+	switch n := value.(type) {
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	}
+	return value.(float64), nil
+}
+
+// GetUint retrieves an unsigned integer from the file specified by a string
+// of the same format as that expected by Child.  The scalar must be a plain
+// integer, as resolved by Resolve, which is not negative.
+func (f *File) GetUint(spec string) (uint64, error) {
+	value, err := f.resolve(spec, IntTag)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n := value.(type) {
+	case int64:
+		if n < 0 {
+			return 0, fmt.Errorf("yaml: %s: %d overflows uint64", spec, n)
+		}
+		return uint64(n), nil
+	}
+	return value.(uint64), nil
+}
+
+// GetDuration retrieves a duration, such as "1m30s", from the file specified
+// by a string of the same format as that expected by Child.  The scalar is
+// parsed by time.ParseDuration.
+func (f *File) GetDuration(spec string) (time.Duration, error) {
+	s, err := f.Get(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("yaml: %s: %s", spec, err)
+	}
+	return d, nil
+}
+
+// GetTime retrieves a time from the file specified by a string of the same
+// format as that expected by Child.  The scalar must be a plain timestamp,
+// such as "2013-05-14" or "2013-05-14T09:30:00Z", as resolved by Resolve.
+func (f *File) GetTime(spec string) (time.Time, error) {
+	value, err := f.resolve(spec, TimestampTag)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return value.(time.Time), nil
+}
 
-	return b, nil
+// byteUnits are the units understood by GetBytes, with the number of bytes in
+// each.
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"kB":  1e3,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"PiB": 1 << 50,
+}
+
+// GetBytes retrieves a number of bytes, such as "512MiB", from the file
+// specified by a string of the same format as that expected by Child.  The
+// scalar is a number, which may have a fraction, followed by an optional
+// unit: B, kB, MB, GB, TB and PB are powers of 1000, while KiB, MiB, GiB, TiB
+// and PiB are powers of 1024.  A space may separate the number and the unit.
+func (f *File) GetBytes(spec string) (int64, error) {
+	s, err := f.Get(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := parseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("yaml: %s: %s", spec, err)
+	}
+	return n, nil
+}
+
+// parseBytes parses a number of bytes for GetBytes.
+func parseBytes(s string) (int64, error) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	number, unit := s[:end], strings.TrimPrefix(s[end:], " ")
+
+	scale, ok := byteUnits[unit]
+	if !ok || !CoreSchema.isFloat(number) {
+		return 0, fmt.Errorf("%q is not a number of bytes", s)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number of bytes", s)
+	}
+	if n *= scale; n >= math.MaxInt64 {
+		return 0, fmt.Errorf("%q overflows int64", s)
+	}
+	return int64(n), nil
+}
+
+// GetStringList retrieves a list of strings from the file specified by a
+// string of the same format as that expected by Child.  Each item of the
+// List must be a Scalar.
+func (f *File) GetStringList(spec string) ([]string, error) {
+	node, err := f.child(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	lst, ok := node.(List)
+	if !ok {
+		return nil, &NodeTypeMismatch{
+			Full:     spec,
+			Spec:     spec,
+			Token:    "$",
+			Expected: "yaml.List",
+			Node:     node,
+		}
+	}
+
+	strs := make([]string, len(lst))
+	for i, item := range lst {
+		s, ok := item.(Scalar)
+		if !ok {
+			return nil, &NodeTypeMismatch{
+				Full:     spec,
+				Spec:     fmt.Sprintf("%s[%d]", spec, i),
+				Token:    "$",
+				Expected: "yaml.Scalar",
+				Node:     item,
+			}
+		}
+		strs[i] = s.String()
+	}
+	return strs, nil
+}
+
+// GetStringMap retrieves a map of strings from the file specified by a string
+// of the same format as that expected by Child.  Each value of the Map must
+// be a Scalar.
+func (f *File) GetStringMap(spec string) (map[string]string, error) {
+	node, err := f.child(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := node.(Map)
+	if !ok {
+		return nil, &NodeTypeMismatch{
+			Full:     spec,
+			Spec:     spec,
+			Token:    "$",
+			Expected: "yaml.Map",
+			Node:     node,
+		}
+	}
+
+	strs := make(map[string]string, len(m))
+	for key, value := range m {
+		s, ok := value.(Scalar)
+		if !ok {
+			return nil, &NodeTypeMismatch{
+				Full:     spec,
+				Spec:     keyPath(spec, key),
+				Token:    "$",
+				Expected: "yaml.Scalar",
+				Node:     value,
+			}
+		}
+		strs[key] = s.String()
+	}
+	return strs, nil
+}
+
+// missing reports whether err was returned because a node does not exist.
+func missing(err error) bool {
+	_, ok := err.(*NodeNotFound)
+	return ok
+}
+
+// GetOr is like Get, but returns def if the node specified by spec, or one of
+// its parents, does not exist.  Any other error, such as a node of the wrong
+// type, is still returned.  The other Get methods have Or variants which
+// behave the same way.
+func (f *File) GetOr(spec, def string) (string, error) {
+	v, err := f.Get(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetIntOr is like GetInt, but returns def if the node does not exist.
+func (f *File) GetIntOr(spec string, def int64) (int64, error) {
+	v, err := f.GetInt(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetBoolOr is like GetBool, but returns def if the node does not exist.
+func (f *File) GetBoolOr(spec string, def bool) (bool, error) {
+	v, err := f.GetBool(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetFloatOr is like GetFloat, but returns def if the node does not exist.
+func (f *File) GetFloatOr(spec string, def float64) (float64, error) {
+	v, err := f.GetFloat(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetUintOr is like GetUint, but returns def if the node does not exist.
+func (f *File) GetUintOr(spec string, def uint64) (uint64, error) {
+	v, err := f.GetUint(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetDurationOr is like GetDuration, but returns def if the node does not
+// exist.
+func (f *File) GetDurationOr(spec string, def time.Duration) (time.Duration, error) {
+	v, err := f.GetDuration(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetTimeOr is like GetTime, but returns def if the node does not exist.
+func (f *File) GetTimeOr(spec string, def time.Time) (time.Time, error) {
+	v, err := f.GetTime(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetBytesOr is like GetBytes, but returns def if the node does not exist.
+func (f *File) GetBytesOr(spec string, def int64) (int64, error) {
+	v, err := f.GetBytes(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetStringListOr is like GetStringList, but returns def if the node does not
+// exist.
+func (f *File) GetStringListOr(spec string, def []string) ([]string, error) {
+	v, err := f.GetStringList(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// GetStringMapOr is like GetStringMap, but returns def if the node does not
+// exist.
+func (f *File) GetStringMapOr(spec string, def map[string]string) (map[string]string, error) {
+	v, err := f.GetStringMap(spec)
+	if missing(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// child retrieves the node specified by spec, which must exist.
+func (f *File) child(spec string) (Node, error) {
+	node, err := Child(f.Root, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, &NodeNotFound{
+			Full: spec,
+			Spec: spec,
+		}
+	}
+	return node, nil
+}
+
+// SetScalar replaces the text of the Scalar specified by spec, using the
+// same format as that expected by Child.  The node keeps its comments, tag
+// and the way it was written, so that Render writes the file back with only
+// the value changed, unless a plain scalar cannot hold the new value, in
+// which case it is double-quoted.
+func (f *File) SetScalar(spec, value string) error {
+	if _, _, err := f.scalar(spec); err != nil {
+		return err
+	}
+	return f.edit(spec, false, func(node Node, n *note) (Node, *note, error) {
+		set := n.setScalar(Scalar(value))
+		return set.node, set, nil
+	})
+}
+
+// Position returns the position in the source at which the node specified
+// by spec, using the same format as that expected by Child, was found.  For a
+// File created by ReadFile, the position includes the file name.  The
+// position of a node which was not read by the parser is the zero Position.
+func (f *File) Position(spec string) (Position, error) {
+	node, n, err := f.lookup(spec)
+	if err != nil {
+		return Position{}, err
+	}
+
+	if node == nil {
+		return Position{}, &NodeNotFound{
+			Full: spec,
+			Spec: spec,
+		}
+	}
+	return positionOf(n), nil
+}
+
+// Info returns the details of how the node specified by spec, using the same
+// format as that expected by Child, was written.  Changes to them are kept
+// in the file, so that Render writes them: for instance, setting the
+// Comment of a node gives it a comment.  The details of a node which was not
+// read by the parser start out empty.
+func (f *File) Info(spec string) (*NodeInfo, error) {
+	var info *NodeInfo
+	err := f.edit(spec, false, func(node Node, n *note) (Node, *note, error) {
+		if node == nil {
+			return nil, nil, &NodeNotFound{
+				Full: spec,
+				Spec: spec,
+			}
+		}
+		if n == nil {
+			n = &note{node: node}
+		}
+		info = &n.NodeInfo
+		return node, n, nil
+	})
+	return info, err
+}
+
+// Keys returns the keys of the Map specified by spec, using the same format
+// as that expected by Child, in the order in which they were written,
+// followed by any keys added since, sorted.  It returns nil if the node is
+// not a Map.
+func (f *File) Keys(spec string) ([]string, error) {
+	node, n, err := f.lookup(spec)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := node.(Map)
+	if !ok {
+		return nil, nil
+	}
+	return keysOf(m, n), nil
+}
+
+// Tag returns the tag of the node specified by spec, using the same format
+// as that expected by Child.  This is the tag it was given explicitly, if
+// any; otherwise it is resolved as by TagOf, except that quoted and block
+// scalars are "!!str" and plain scalars are resolved according to the
+// schema by which the file was read.  Tag returns "" for an empty value.
+func (f *File) Tag(spec string) (string, error) {
+	node, n, err := f.lookup(spec)
+	if err != nil {
+		return "", err
+	}
+	return tagOf(node, n, f.schema), nil
+}
+
+// Resolve returns the kind of value held by the node specified by spec, using
+// the same format as that expected by Child, and the value itself, as the
+// Resolve function does.  The kind is the node's tag if it was given one of
+// the core schema, "!!str" if it was quoted, and otherwise is resolved from
+// its text according to the schema by which the file was read.
+func (f *File) Resolve(spec string) (kind string, value interface{}, err error) {
+	node, n, err := f.lookup(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	return resolve(node, n, f.schema)
 }
 
 // Count retrieves a the number of elements in the specified list from the file
@@ -171,10 +640,87 @@ func (f *File) Require(spec string) string {
 	return str
 }
 
+// RequireFloat is like GetFloat, but panics if it would return an error.
+// This is a convenience function for use in initializers.
+func (f *File) RequireFloat(spec string) float64 {
+	v, err := f.GetFloat(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireUint is like GetUint, but panics if it would return an error.  This
+// is a convenience function for use in initializers.
+func (f *File) RequireUint(spec string) uint64 {
+	v, err := f.GetUint(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireDuration is like GetDuration, but panics if it would return an
+// error.  This is a convenience function for use in initializers.
+func (f *File) RequireDuration(spec string) time.Duration {
+	v, err := f.GetDuration(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireTime is like GetTime, but panics if it would return an error.  This
+// is a convenience function for use in initializers.
+func (f *File) RequireTime(spec string) time.Time {
+	v, err := f.GetTime(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireBytes is like GetBytes, but panics if it would return an error.
+// This is a convenience function for use in initializers.
+func (f *File) RequireBytes(spec string) int64 {
+	v, err := f.GetBytes(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireStringList is like GetStringList, but panics if it would return an
+// error.  This is a convenience function for use in initializers.
+func (f *File) RequireStringList(spec string) []string {
+	v, err := f.GetStringList(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// RequireStringMap is like GetStringMap, but panics if it would return an
+// error.  This is a convenience function for use in initializers.
+func (f *File) RequireStringMap(spec string) map[string]string {
+	v, err := f.GetStringMap(spec)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 // Child retrieves a child node from the specified node as follows:
 //   .mapkey   - Get the key 'mapkey' of the Node, which must be a Map
+//   ["key"]   - The same, for a key which holds '.', '[' or ']'; the key may
+//               be single- or double-quoted, and may also follow a '.'
 //   [idx]     - Choose the index from the current Node, which must be a List
 //
+// A backslash in an unquoted key escapes the character after it, so
+// `hosts.example\.com` also names the key "example.com"; BuildPath writes
+// a path from its keys and indices.  A spec which is not well formed, or
+// which uses a selector of Select, is reported as a *PathError.
+//
 // The above selectors may be applied recursively, and each successive selector
 // applies to the result of the previous selector.  For convenience, a "." is
 // implied as the first character if the first character is not a "." or "[".
@@ -183,83 +729,11 @@ func (f *File) Require(spec string) string {
 // returned. If a node is not the proper type, an error is returned.  If the
 // final node is not a Scalar, an error is returned.
 func Child(root Node, spec string) (Node, error) {
-	if len(spec) == 0 {
-		return root, nil
-	}
-
-	if first := spec[0]; first != '.' && first != '[' {
-		spec = "." + spec
-	}
-
-	var recur func(Node, string, string) (Node, error)
-	recur = func(n Node, last, s string) (Node, error) {
-
-		if len(s) == 0 {
-			return n, nil
-		}
-
-		if n == nil {
-			return nil, &NodeNotFound{
-				Full: spec,
-				Spec: last,
-			}
-		}
-
-		// Extract the next token
-		delim := 1 + strings.IndexAny(s[1:], ".[")
-		if delim <= 0 {
-			delim = len(s)
-		}
-		tok := s[:delim]
-		remain := s[delim:]
-
-		switch s[0] {
-		case '[':
-			s, ok := n.(List)
-			if !ok {
-				return nil, &NodeTypeMismatch{
-					Node:     n,
-					Expected: "yaml.List",
-					Full:     spec,
-					Spec:     last,
-					Token:    tok,
-				}
-			}
-
-			if tok[0] == '[' && tok[len(tok)-1] == ']' {
-				if num, err := strconv.Atoi(tok[1 : len(tok)-1]); err == nil {
-					if num >= 0 && num < len(s) {
-						return recur(s[num], last+tok, remain)
-					}
-				}
-			}
-			return nil, &NodeNotFound{
-				Full: spec,
-				Spec: last + tok,
-			}
-		default:
-			m, ok := n.(Map)
-			if !ok {
-				return nil, &NodeTypeMismatch{
-					Node:     n,
-					Expected: "yaml.Map",
-					Full:     spec,
-					Spec:     last,
-					Token:    tok,
-				}
-			}
-
-			n, ok = m[tok[1:]]
-			if !ok {
-				return nil, &NodeNotFound{
-					Full: spec,
-					Spec: last + tok,
-				}
-			}
-			return recur(n, last+tok, remain)
-		}
+	p, err := CompilePath(spec)
+	if err != nil {
+		return nil, err
 	}
-	return recur(root, "", spec)
+	return p.Child(root)
 }
 
 type NodeNotFound struct {
@@ -15,7 +15,13 @@
 package yaml
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var dummyConfigFile = `
@@ -25,6 +31,8 @@ mapping:
   key3: 5
   key4: true
   key5: false
+  key6: "true"
+  key7: '42'
 list:
   - item1
   - item2
@@ -47,6 +55,8 @@ var configGetTests = []struct {
 }{
 	{"mapping.key1", "value1", ""},
 	{"mapping.key2", "value2", ""},
+	{"mapping.key6", "true", ""},
+	{"mapping.key7", "42", ""},
 	{"list[0]", "item1", ""},
 	{"list[1]", "item2", ""},
 	{"list", "", `yaml: list: type mismatch: "list" is yaml.List, want yaml.Scalar (at "$")`},
@@ -96,4 +106,339 @@ func TestGet(t *testing.T) {
 		t.Errorf("GetBool mapping.key5 wrong")
 	}
 
+	if _, err := config.GetBool("mapping.key6"); err == nil {
+		t.Errorf("GetBool mapping.key6 converted a quoted scalar")
+	}
+
+	if _, err := config.GetInt("mapping.key7"); err == nil {
+		t.Errorf("GetInt mapping.key7 converted a quoted scalar")
+	}
+
+}
+
+func TestGetTrailingComment(t *testing.T) {
+	config := Config("port: 8080  # http port\n" +
+		"host: example.com #primary\n" +
+		"url:  http://x.com/#top\n")
+
+	if got, err := config.GetInt("port"); err != nil || got != 8080 {
+		t.Errorf("GetInt(port) = %d, %v, want 8080", got, err)
+	}
+	if got, err := config.Get("host"); err != nil || got != "example.com" {
+		t.Errorf("Get(host) = %q, %v, want example.com", got, err)
+	}
+	if got, err := config.Get("url"); err != nil || got != "http://x.com/#top" {
+		t.Errorf("Get(url) = %q, %v, want http://x.com/#top", got, err)
+	}
+}
+
+func TestGetResolved(t *testing.T) {
+	config := Config("hex:    0x1F\n" +
+		"big:    1_000_000\n" +
+		"huge:   18446744073709551615\n" +
+		"float:  1.5\n" +
+		"word:   yes\n" +
+		"upper:  TRUE\n" +
+		"digit:  1\n")
+
+	for spec, want := range map[string]int64{"hex": 31} {
+		if got, err := config.GetInt(spec); err != nil || got != want {
+			t.Errorf("GetInt(%q) = %d, %v, want %d", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"huge", "float", "word", "big"} {
+		if got, err := config.GetInt(spec); err == nil {
+			t.Errorf("GetInt(%q) = %d, want an error", spec, got)
+		}
+	}
+	if _, err := config.GetInt("float"); err != nil {
+		if _, ok := err.(*NodeTypeMismatch); !ok {
+			t.Errorf("GetInt(float) error = %#v, want a *NodeTypeMismatch", err)
+		}
+	}
+
+	if got, err := config.GetBool("upper"); err != nil || !got {
+		t.Errorf("GetBool(upper) = %v, %v, want true", got, err)
+	}
+	for _, spec := range []string{"word", "digit"} {
+		if got, err := config.GetBool(spec); err == nil {
+			t.Errorf("GetBool(%q) = %v, want an error", spec, got)
+		}
+	}
+
+	f, err := (&Parser{Schema: YAML11Schema}).ParseFile(strings.NewReader("word: yes\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if got, err := f.GetBool("word"); err != nil || !got {
+		t.Errorf("GetBool(word) in YAML 1.1 = %v, %v, want true", got, err)
+	}
+}
+
+var typedConfigFile = `
+ratio:    0.75
+count:    3
+negative: -3
+timeout:  1m30s
+started:  2013-05-14T09:30:00Z
+quoted:   "2013-05-14"
+buffer:   512MiB
+disk:     1.5 GB
+plain:    4096
+bad:      12 parsecs
+hosts:    [a, b, c]
+nested:   [a, [b]]
+labels:
+  app:  web
+  tier: frontend
+`
+
+func TestGetTyped(t *testing.T) {
+	config := Config(typedConfigFile)
+
+	tests := []struct {
+		Spec string
+		Get  func(spec string) (interface{}, error)
+		Want interface{}
+		Err  string
+	}{
+		{"ratio", func(s string) (interface{}, error) { return config.GetFloat(s) }, 0.75, ""},
+		{"count", func(s string) (interface{}, error) { return config.GetFloat(s) }, 3.0, ""},
+		{"hosts", func(s string) (interface{}, error) { return config.GetFloat(s) }, 0.0,
+			`yaml: hosts: type mismatch: "hosts" is yaml.List, want yaml.Scalar (at "$")`},
+		{"count", func(s string) (interface{}, error) { return config.GetUint(s) }, uint64(3), ""},
+		{"negative", func(s string) (interface{}, error) { return config.GetUint(s) }, uint64(0),
+			`yaml: negative: -3 overflows uint64`},
+		{"ratio", func(s string) (interface{}, error) { return config.GetUint(s) }, uint64(0),
+			`yaml: ratio: type mismatch: "ratio" is yaml.Scalar, want yaml.Scalar holding !!int (at "$")`},
+		{"timeout", func(s string) (interface{}, error) { return config.GetDuration(s) }, 90 * time.Second, ""},
+		{"count", func(s string) (interface{}, error) { return config.GetDuration(s) }, time.Duration(0),
+			`yaml: count: time: missing unit in duration "3"`},
+		{"started", func(s string) (interface{}, error) { return config.GetTime(s) },
+			time.Date(2013, 5, 14, 9, 30, 0, 0, time.UTC), ""},
+		{"quoted", func(s string) (interface{}, error) { return config.GetTime(s) }, time.Time{},
+			`yaml: quoted: type mismatch: "quoted" is yaml.Scalar, want yaml.Scalar holding !!timestamp (at "$")`},
+		{"buffer", func(s string) (interface{}, error) { return config.GetBytes(s) }, int64(512 << 20), ""},
+		{"disk", func(s string) (interface{}, error) { return config.GetBytes(s) }, int64(1500000000), ""},
+		{"plain", func(s string) (interface{}, error) { return config.GetBytes(s) }, int64(4096), ""},
+		{"bad", func(s string) (interface{}, error) { return config.GetBytes(s) }, int64(0),
+			`yaml: bad: "12 parsecs" is not a number of bytes`},
+		{"hosts", func(s string) (interface{}, error) { return config.GetStringList(s) }, []string{"a", "b", "c"}, ""},
+		{"nested", func(s string) (interface{}, error) { return config.GetStringList(s) }, []string(nil),
+			`yaml: nested: type mismatch: "nested[1]" is yaml.List, want yaml.Scalar (at "$")`},
+		{"labels", func(s string) (interface{}, error) { return config.GetStringList(s) }, []string(nil),
+			`yaml: labels: type mismatch: "labels" is yaml.Map, want yaml.List (at "$")`},
+		{"labels", func(s string) (interface{}, error) { return config.GetStringMap(s) },
+			map[string]string{"app": "web", "tier": "frontend"}, ""},
+		{"missing", func(s string) (interface{}, error) { return config.GetStringMap(s) }, map[string]string(nil),
+			`yaml: .missing: ".missing" not found`},
+	}
+	for _, test := range tests {
+		got, err := test.Get(test.Spec)
+		if errStr := fmt.Sprint(err); test.Err == "" && err != nil || test.Err != "" && errStr != test.Err {
+			t.Errorf("%s: error = %v, want %q", test.Spec, err, test.Err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("%s = %#v, want %#v", test.Spec, got, test.Want)
+		}
+	}
+
+	if got := config.RequireBytes("buffer"); got != 512<<20 {
+		t.Errorf("RequireBytes(buffer) = %d, want %d", got, 512<<20)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RequireStringMap(hosts) did not panic")
+		}
+	}()
+	config.RequireStringMap("hosts")
+}
+
+func TestGetOr(t *testing.T) {
+	config := Config(typedConfigFile)
+
+	if got, err := config.GetOr("missing", "def"); err != nil || got != "def" {
+		t.Errorf("GetOr(missing) = %q, %v, want def", got, err)
+	}
+	if got, err := config.GetOr("labels.app", "def"); err != nil || got != "web" {
+		t.Errorf("GetOr(labels.app) = %q, %v, want web", got, err)
+	}
+	if got, err := config.GetIntOr("missing.deeper[3]", 7); err != nil || got != 7 {
+		t.Errorf("GetIntOr(missing.deeper[3]) = %d, %v, want 7", got, err)
+	}
+	if got, err := config.GetStringListOr("hosts", nil); err != nil || len(got) != 3 {
+		t.Errorf("GetStringListOr(hosts) = %q, %v, want 3 hosts", got, err)
+	}
+	if got, err := config.GetStringListOr("hosts[5]", []string{"x"}); err != nil || len(got) != 1 {
+		t.Errorf("GetStringListOr(hosts[5]) = %q, %v, want [x]", got, err)
+	}
+	if got, err := config.GetDurationOr("retry", time.Second); err != nil || got != time.Second {
+		t.Errorf("GetDurationOr(retry) = %s, %v, want 1s", got, err)
+	}
+
+	// Only a missing node gives the default.
+	errs := []error{}
+	_, err := config.GetBoolOr("ratio", true)
+	errs = append(errs, err)
+	_, err = config.GetFloatOr("hosts", 1)
+	errs = append(errs, err)
+	_, err = config.GetBytesOr("bad", 1)
+	errs = append(errs, err)
+	_, err = config.GetOr("hosts.first", "def")
+	errs = append(errs, err)
+	_, err = config.GetTimeOr("quoted", time.Time{})
+	errs = append(errs, err)
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("%d. Or variant returned the default instead of an error", i)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "gypsy")
+	if err != nil {
+		t.Fatalf("tempfile: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString(dummyConfigFile)
+	tmp.Close()
+
+	config, err := ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatalf("readfile: %s", err)
+	}
+
+	pos, err := config.Position("config.admin[1].password")
+	if err != nil {
+		t.Fatalf("position: %s", err)
+	}
+	if got, want := pos.String(), tmp.Name()+":22:17"; got != want {
+		t.Errorf("position = %q, want %q", got, want)
+	}
+
+	if _, err := config.Position("config.missing"); err == nil {
+		t.Errorf("position of a missing node succeeded")
+	}
+}
+
+var commentedConfigFile = `# Service configuration.
+
+name: gypsy  # the service name
+version: 1.4.2
+
+# Where to listen.
+server:
+  host:    0.0.0.0
+  port:    8080 # http
+  tls: {cert: a.pem, key: a.key}
+  # Trailing comment for server.
+
+backends:
+  - primary   # the main one
+  - "replica"
+hook: |
+  echo started
+
+
+# Resource limits.
+limits:
+    cpu: 2
+    memory: |  # in MiB
+      512
+# The end.
+`
+
+func TestRoundTrip(t *testing.T) {
+	config := Config(commentedConfigFile)
+	if got, want := config.Render(), commentedConfigFile; got != want {
+		t.Errorf("Render:\n%s\nwant:\n%s", got, want)
+	}
+	if got := config.RenderSorted(); strings.Contains(got, "#") {
+		t.Errorf("RenderSorted kept comments:\n%s", got)
+	}
+
+	for _, test := range roundTripTests {
+		f, err := new(Parser).ParseFile(strings.NewReader(test.Input))
+		if err != nil {
+			t.Errorf("parse %q: %s", test.Input, err)
+			continue
+		}
+		got := f.Render()
+		if got != test.Output {
+			t.Errorf("Render(%q) = %q, want %q", test.Input, got, test.Output)
+		}
+		again, err := Parse(strings.NewReader(got))
+		if err != nil {
+			t.Errorf("parse %q: %s", got, err)
+			continue
+		}
+		if !reflect.DeepEqual(again, f.Root) {
+			t.Errorf("Render(%q) = %q, read back as %#v, want %#v", test.Input, got, again, f.Root)
+		}
+	}
+}
+
+var roundTripTests = []struct {
+	Input  string
+	Output string
+}{
+	{"empty:\nnext: 1\n", "empty:\nnext: 1\n"},
+	{"a:\n  b:\n  c: x\n", "a:\n  b:\n  c: x\n"},
+	{"- \n- x\n", "-\n- x\n"},
+	{"a:\n  -\n  - x\n", "a:\n  -\n  - x\n"},
+	{"--- key:\n  x: 1\n", "key:\n  x: 1\n"},
+	{"!t key:\n  x: 1\n", "--- !t\nkey:\n  x: 1\n"},
+	{"---   a:\n  x: 1\n", "a:\n  x: 1\n"},
+	{"a: b: c:\n  x: 1\n", "a:\n  b:\n    c:\n      x: 1\n"},
+}
+
+var setScalarTests = []struct {
+	Spec  string
+	Value string
+	From  string
+	To    string
+	Err   string
+}{
+	{"version", "1.5.0", "version: 1.4.2\n", "version: 1.5.0\n", ""},
+	{"server.port", "9090", "  port:    8080 # http\n", "  port:    9090 # http\n", ""},
+	{"backends[1]", "standby", `  - "replica"` + "\n", `  - "standby"` + "\n", ""},
+	{"name", "a: b", "name: gypsy  # the service name\n", `name: "a: b"  # the service name` + "\n", ""},
+	{"server.tls.key", "b.key", "  tls: {cert: a.pem, key: a.key}\n", "  tls: {cert: a.pem, key: b.key}\n", ""},
+	{"server", "x", "", "", `yaml: server: type mismatch: "server" is yaml.Map, want yaml.Scalar (at "$")`},
+	{"server.missing", "x", "", "", `yaml: .server.missing: ".server.missing" not found`},
+}
+
+func TestSetScalar(t *testing.T) {
+	for _, test := range setScalarTests {
+		config := Config(commentedConfigFile)
+		err := config.SetScalar(test.Spec, test.Value)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if want := test.Err; got != want {
+			t.Errorf("SetScalar(%q) error = %q, want %q", test.Spec, got, want)
+		}
+		if err != nil {
+			continue
+		}
+
+		if got, want := config.Require(test.Spec), test.Value; got != want {
+			t.Errorf("after SetScalar(%q), Get = %q, want %q", test.Spec, got, want)
+		}
+		want := strings.Replace(commentedConfigFile, test.From, test.To, 1)
+		if got := config.Render(); got != want {
+			t.Errorf("after SetScalar(%q), Render:\n%s\nwant:\n%s", test.Spec, got, want)
+		}
+	}
+
+	config := &File{Root: Map{"a": List{Scalar("x")}}}
+	if err := config.SetScalar("a[0]", "- y"); err != nil {
+		t.Fatalf("SetScalar of an unannotated node: %s", err)
+	}
+	if got, want := config.Render(), "a:\n  - \"- y\"\n"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
 }
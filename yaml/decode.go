@@ -0,0 +1,612 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decode stores the contents of the file in the value pointed to by v.  See
+// Unmarshal for how nodes are stored in Go values.
+func (f *File) Decode(v interface{}) error {
+	return f.decode(v, false)
+}
+
+// DecodeStrict is like Decode, but it is an error for a key not to match a
+// struct field, as for UnmarshalStrict.
+func (f *File) DecodeStrict(v interface{}) error {
+	return f.decode(v, true)
+}
+
+// decode stores the file in v, taking into account which of its scalars were
+// quoted or tagged and the schema by which it was read.
+func (f *File) decode(v interface{}, strict bool) error {
+	d := &decoder{strict: strict, schema: f.schema}
+	return d.unmarshal(f.Root, f.notes.of(f.Root), v)
+}
+
+// Unmarshal stores node in the value pointed to by v, which must be a
+// non-nil pointer.
+//
+// A Map is stored in a struct or in a map with keys which can be decoded from
+// a Scalar.  Each key of a Map is stored in the exported struct field with
+// the same name in lower case, unless the field has a tag such as
+// `yaml:"name"` giving it another name.  A tag of "-" ignores the field.  A
+// struct or map field with the "inline" option, `yaml:",inline"`, is treated
+// as if its fields, or keys, were part of the outer struct; an inline map
+// receives the keys which no field matches.  Keys which no field matches are
+// otherwise ignored.
+//
+// A List is stored in a slice or in an array of the same length.  A Scalar is
+// stored in a string as it is, or in a bool, an integer, a float or a
+// time.Time if it holds one, as resolved by Resolve; File.Decode stores a
+// quoted scalar, or one tagged "!!str", only in a string.  A time.Duration is
+// written as accepted by time.ParseDuration, and any type which implements
+// encoding.TextUnmarshaler decodes itself from the text of a Scalar.  A type
+// which implements Unmarshaler decodes itself from any node.  A null sets a
+// pointer, map, slice or interface to nil and leaves other values alone;
+// pointers are allocated as needed.  Into an empty interface, Unmarshal
+// stores a map[string]interface{}, an []interface{}, or the value of a Scalar
+// as returned by Resolve.
+//
+// Unmarshal stores as much of node as it can.  If any node cannot be stored,
+// it returns a DecodeErrors listing every such node.
+func Unmarshal(node Node, v interface{}) error {
+	return (&decoder{}).unmarshal(node, nil, v)
+}
+
+// UnmarshalStrict is like Unmarshal, but a key of a Map which matches no
+// field of the struct it is stored in, and which is not received by an
+// inline map, is reported as an error.  Every such key is listed in the
+// DecodeErrors.  To reject duplicate keys as well, parse the node with a
+// strict Parser.
+func UnmarshalStrict(node Node, v interface{}) error {
+	return (&decoder{strict: true}).unmarshal(node, nil, v)
+}
+
+// unmarshal stores node, which is described by n, in the value pointed to by
+// v.
+func (d *decoder) unmarshal(node Node, n *note, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("yaml: Unmarshal needs a non-nil pointer, not %T", v)
+	}
+
+	d.decode("", node, n, rv.Elem())
+	if len(d.errors) > 0 {
+		return d.errors
+	}
+	return nil
+}
+
+// An Unmarshaler is a value which can decode itself from a Node, which may be
+// a Map, a List or a Scalar.  An error it returns is reported by Unmarshal at
+// the path of the node; if it is a DecodeErrors, as returned by calling
+// Unmarshal on the node or its children, their paths are taken to be
+// relative to the node.
+type Unmarshaler interface {
+	UnmarshalYAML(node Node) error
+}
+
+// A DecodeError describes a node which could not be stored in a Go value.
+type DecodeError struct {
+	Path string   // the path of the node, as accepted by Child
+	Pos  Position // the position of the node, if it was read by the parser
+	Err  error    // what went wrong
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("yaml: %s: %s: %s", e.Pos, path, e.Err)
+	}
+	return fmt.Sprintf("yaml: %s: %s", path, e.Err)
+}
+
+// DecodeErrors is returned by Unmarshal and File.Decode when nodes could not
+// be stored.  It lists each of them, in the order in which they were found.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("yaml: %d errors decoding:", len(e)))
+	for _, err := range e {
+		lines = append(lines, "\t"+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A decoder collects the errors found while storing nodes.
+type decoder struct {
+	strict bool   // see UnmarshalStrict
+	schema Schema // the schema by which plain scalars are resolved
+	errors DecodeErrors
+}
+
+// fail records an error for the node at path, which is described by n.
+func (d *decoder) fail(path string, n *note, format string, args ...interface{}) {
+	d.errors = append(d.errors, &DecodeError{
+		Path: path,
+		Pos:  positionOf(n),
+		Err:  fmt.Errorf(format, args...),
+	})
+}
+
+// wrap records an error returned by an Unmarshaler for node at path, which
+// is described by n.  The errors of nodes beneath it which were decoded
+// without their notes are given the positions n records for them.
+func (d *decoder) wrap(path string, node Node, n *note, err error) {
+	errs, ok := err.(DecodeErrors)
+	if !ok {
+		d.errors = append(d.errors, &DecodeError{Path: path, Pos: positionOf(n), Err: err})
+		return
+	}
+	for _, e := range errs {
+		inner := *e
+		inner.Path = joinPath(path, e.Path)
+		if p, err := CompilePath(e.Path); err == nil && !inner.Pos.IsValid() {
+			if _, in, err := p.find(node, n); err == nil {
+				inner.Pos = positionOf(in)
+			}
+		}
+		d.errors = append(d.errors, &inner)
+	}
+}
+
+// joinPath returns the path of a node at the relative path rel from the node
+// at path.
+func joinPath(path, rel string) string {
+	if rel == "" || strings.HasPrefix(rel, "[") {
+		return path + rel
+	}
+	if path == "" {
+		return rel
+	}
+	return path + "." + rel
+}
+
+// keyPath and indexPath extend the path of a node to one of its children.
+// A key which could not be read back as written is quoted in brackets.
+func keyPath(path, key string) string {
+	switch {
+	case needsPathQuotes(key):
+		return path + "[" + strconv.Quote(key) + "]"
+	case path == "":
+		return key
+	}
+	return path + "." + key
+}
+
+func indexPath(path string, idx int) string {
+	return fmt.Sprintf("%s[%d]", path, idx)
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isNull reports whether node, which is described by n, is missing or is a
+// plain null scalar.
+func (d *decoder) isNull(node Node, n *note) bool {
+	if node == nil {
+		return true
+	}
+	_, ok := node.(Scalar)
+	return ok && tagOf(node, n, d.schema) == NullTag
+}
+
+func (d *decoder) decode(path string, node Node, n *note, v reflect.Value) {
+	if d.isNull(node, n) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(path, node, n, v.Elem())
+		return
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			if value := d.generic(path, node, n); value != nil {
+				v.Set(reflect.ValueOf(value))
+			} else {
+				v.Set(reflect.Zero(v.Type()))
+			}
+			return
+		}
+		if e := v.Elem(); e.Kind() == reflect.Ptr && !e.IsNil() {
+			d.decode(path, node, n, e)
+			return
+		}
+		d.fail(path, n, "cannot decode %s into %s", nodeKind(node), v.Type())
+		return
+	}
+
+	if u, ok := implementer(v, unmarshalerType); ok {
+		if err := u.Interface().(Unmarshaler).UnmarshalYAML(node); err != nil {
+			d.wrap(path, node, n, err)
+		}
+		return
+	}
+
+	switch node := node.(type) {
+	case Map:
+		d.decodeMap(path, node, n, v)
+	case List:
+		d.decodeList(path, node, n, v)
+	case Scalar:
+		d.decodeScalar(path, node, n, v)
+	default:
+		d.fail(path, n, "cannot decode %s into %s", nodeKind(node), v.Type())
+	}
+}
+
+func (d *decoder) decodeMap(path string, m Map, n *note, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		info, err := structInfoOf(v.Type())
+		if err != nil {
+			d.fail(path, n, "%s", err)
+			return
+		}
+		var rest reflect.Value
+		for _, key := range keysOf(m, n) {
+			field, ok := info.byName[key]
+			if !ok {
+				if info.inlineMap == nil {
+					if d.strict {
+						d.fail(keyPath(path, key), n.entry(m, key), "no field of %s matches the key", v.Type())
+					}
+					continue
+				}
+				if !rest.IsValid() {
+					rest = fieldByIndex(v, info.inlineMap)
+					if rest.IsNil() {
+						rest.Set(reflect.MakeMap(rest.Type()))
+					}
+				}
+				d.setMapIndex(path, key, m[key], n.entry(m, key), rest)
+				continue
+			}
+			d.decode(keyPath(path, key), m[key], n.entry(m, key), fieldByIndex(v, field.index))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, key := range keysOf(m, n) {
+			d.setMapIndex(path, key, m[key], n.entry(m, key), v)
+		}
+	default:
+		d.fail(path, n, "cannot decode a map into %s", v.Type())
+	}
+}
+
+// setMapIndex decodes key and value, which is described by vn, into a new
+// entry of the map v.  The entry is left out if either cannot be decoded.
+func (d *decoder) setMapIndex(path, key string, value Node, vn *note, v reflect.Value) {
+	kv := reflect.New(v.Type().Key()).Elem()
+	errs := len(d.errors)
+	d.decode(keyPath(path, key), Scalar(key), nil, kv)
+	if len(d.errors) > errs {
+		return
+	}
+	ev := reflect.New(v.Type().Elem()).Elem()
+	if old := v.MapIndex(kv); old.IsValid() {
+		ev.Set(old)
+	}
+	d.decode(keyPath(path, key), value, vn, ev)
+	if len(d.errors) > errs {
+		return
+	}
+	v.SetMapIndex(kv, ev)
+}
+
+func (d *decoder) decodeList(path string, list List, n *note, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			d.decode(indexPath(path, i), item, n.item(list, i), s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Array:
+		if v.Len() != len(list) {
+			d.fail(path, n, "cannot decode a list of %d items into %s", len(list), v.Type())
+			return
+		}
+		for i, item := range list {
+			d.decode(indexPath(path, i), item, n.item(list, i), v.Index(i))
+		}
+	default:
+		d.fail(path, n, "cannot decode a list into %s", v.Type())
+	}
+}
+
+func (d *decoder) decodeScalar(path string, node Scalar, n *note, v reflect.Value) {
+	s := string(node)
+	kind, value, err := resolve(node, n, d.schema)
+	if t, ok := value.(time.Time); ok && v.Type() == timeType {
+		v.Set(reflect.ValueOf(t))
+		return
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			d.fail(path, n, "cannot decode %q into %s: %s", s, v.Type(), err)
+		}
+		return
+	}
+
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return
+	}
+
+	// Only plain scalars hold anything but strings.
+	mismatch := func() {
+		d.fail(path, n, "cannot decode %q into %s", s, v.Type())
+	}
+	overflow := func() {
+		d.fail(path, n, "%q overflows %s", s, v.Type())
+	}
+	if isString(n) {
+		mismatch()
+		return
+	}
+
+	if v.Type() == durationType {
+		dur, err := time.ParseDuration(s)
+		if err != nil {
+			d.fail(path, n, "cannot decode %q into %s: %s", s, v.Type(), err)
+			return
+		}
+		v.SetInt(int64(dur))
+		return
+	}
+
+	// An integer which does not fit in 64 bits fits in no Go integer.
+	if err != nil && kind == IntTag {
+		overflow()
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			mismatch()
+			return
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch i := value.(type) {
+		case int64:
+			if v.OverflowInt(i) {
+				overflow()
+				return
+			}
+			v.SetInt(i)
+		case uint64:
+			overflow()
+		default:
+			mismatch()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch i := value.(type) {
+		case int64:
+			if i < 0 || v.OverflowUint(uint64(i)) {
+				overflow()
+				return
+			}
+			v.SetUint(uint64(i))
+		case uint64:
+			if v.OverflowUint(i) {
+				overflow()
+				return
+			}
+			v.SetUint(i)
+		default:
+			mismatch()
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := value.(type) {
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			mismatch()
+			return
+		}
+		if v.OverflowFloat(f) {
+			overflow()
+			return
+		}
+		v.SetFloat(f)
+	default:
+		d.fail(path, n, "cannot decode a scalar into %s", v.Type())
+	}
+}
+
+// generic returns node, which is described by n, as the value stored in an
+// empty interface.
+func (d *decoder) generic(path string, node Node, n *note) interface{} {
+	switch node := node.(type) {
+	case Map:
+		m := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			m[key] = d.generic(keyPath(path, key), value, n.entry(node, key))
+		}
+		return m
+	case List:
+		l := make([]interface{}, len(node))
+		for i, item := range node {
+			l[i] = d.generic(indexPath(path, i), item, n.item(node, i))
+		}
+		return l
+	case Scalar:
+		if _, value, err := resolve(node, n, d.schema); err == nil {
+			return value
+		}
+		return string(node)
+	}
+	return nil
+}
+
+// A structInfo describes how the keys of a Map are stored in a struct.
+type structInfo struct {
+	fields    []fieldInfo
+	byName    map[string]fieldInfo
+	inlineMap []int // the index of the inline map field, if any
+}
+
+// A fieldInfo describes a struct field, which may be in an inline struct.
+type fieldInfo struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var structInfos = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structInfo
+}{m: make(map[reflect.Type]*structInfo)}
+
+// structInfoOf returns the fields of the struct type t according to their
+// tags.
+func structInfoOf(t reflect.Type) (*structInfo, error) {
+	structInfos.RLock()
+	info, ok := structInfos.m[t]
+	structInfos.RUnlock()
+	if ok {
+		return info, nil
+	}
+
+	info = &structInfo{byName: make(map[string]fieldInfo)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		field := fieldInfo{name: opts[0], index: []int{i}}
+		inline := false
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty":
+				field.omitEmpty = true
+			case "inline":
+				inline = true
+			default:
+				return nil, fmt.Errorf("unknown option %q in the tag of %s.%s", opt, t, f.Name)
+			}
+		}
+
+		if inline {
+			switch f.Type.Kind() {
+			case reflect.Map:
+				if info.inlineMap != nil {
+					return nil, fmt.Errorf("%s has more than one inline map", t)
+				}
+				if f.Type.Key().Kind() != reflect.String {
+					return nil, fmt.Errorf("inline map %s.%s must have string keys", t, f.Name)
+				}
+				info.inlineMap = field.index
+			case reflect.Struct:
+				inner, err := structInfoOf(f.Type)
+				if err != nil {
+					return nil, err
+				}
+				for _, innerField := range inner.fields {
+					innerField.index = append([]int{i}, innerField.index...)
+					if err := info.add(t, innerField); err != nil {
+						return nil, err
+					}
+				}
+				if inner.inlineMap != nil {
+					if info.inlineMap != nil {
+						return nil, fmt.Errorf("%s has more than one inline map", t)
+					}
+					info.inlineMap = append([]int{i}, inner.inlineMap...)
+				}
+			default:
+				return nil, fmt.Errorf("inline field %s.%s must be a struct or a map", t, f.Name)
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue // an unexported embedded type
+		}
+		if field.name == "" {
+			field.name = strings.ToLower(f.Name)
+		}
+		if err := info.add(t, field); err != nil {
+			return nil, err
+		}
+	}
+
+	structInfos.Lock()
+	structInfos.m[t] = info
+	structInfos.Unlock()
+	return info, nil
+}
+
+func (info *structInfo) add(t reflect.Type, field fieldInfo) error {
+	if _, ok := info.byName[field.name]; ok {
+		return fmt.Errorf("%s has more than one field for the key %q", t, field.name)
+	}
+	info.fields = append(info.fields, field)
+	info.byName[field.name] = field
+	return nil
+}
+
+// fieldByIndex returns the field of the struct v with the given index.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		v = v.Field(i)
+	}
+	return v
+}
@@ -0,0 +1,381 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeServer struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+	Tags    []string `yaml:",omitempty"`
+	Weight  float64  `yaml:"w"`
+}
+
+type decodeCommon struct {
+	Name    string
+	Enabled bool
+}
+
+type decodeConfig struct {
+	decodeCommon `yaml:",inline"`
+
+	Primary *decodeServer
+	Servers []decodeServer
+	Limits  map[string]uint16
+	Addr    net.IP
+	Started time.Time
+	Ignored string                 `yaml:"-"`
+	Extra   map[string]interface{} `yaml:",inline"`
+}
+
+const decodeInput = `name: gypsy
+enabled: true
+primary:
+  host: db.example.com
+  port: 0x1F90
+  timeout: 1m30s
+servers:
+  - host: a
+    port: 1
+    w: .5
+  - {host: b, port: 2, tags: [x, y]}
+limits:
+  conns: 1000
+  files: 0o777
+addr: 10.0.0.1
+started: 2013-05-14T09:30:00Z
+ignored: nope
+color: blue
+sizes: [1, 2.5, ~, "3"]
+`
+
+func TestDecode(t *testing.T) {
+	var got decodeConfig
+	got.Ignored = "kept"
+	if err := Config(decodeInput).Decode(&got); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	want := decodeConfig{
+		decodeCommon: decodeCommon{Name: "gypsy", Enabled: true},
+		Primary: &decodeServer{
+			Host:    "db.example.com",
+			Port:    8080,
+			Timeout: 90 * time.Second,
+		},
+		Servers: []decodeServer{
+			{Host: "a", Port: 1, Weight: 0.5},
+			{Host: "b", Port: 2, Tags: []string{"x", "y"}},
+		},
+		Limits:  map[string]uint16{"conns": 1000, "files": 0777},
+		Addr:    net.ParseIP("10.0.0.1"),
+		Started: time.Date(2013, 5, 14, 9, 30, 0, 0, time.UTC),
+		Ignored: "kept",
+		Extra: map[string]interface{}{
+			"color":   "blue",
+			"ignored": "nope",
+			"sizes":   []interface{}{int64(1), 2.5, nil, "3"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+// A byteSize decodes itself from a number of bytes with an optional unit.
+type byteSize int64
+
+func (b *byteSize) UnmarshalYAML(node Node) error {
+	s, ok := node.(Scalar)
+	if !ok {
+		return fmt.Errorf("cannot decode %s into a size", nodeKind(node))
+	}
+	text, scale := string(s), int64(1)
+	for _, unit := range []struct {
+		suffix string
+		scale  int64
+	}{{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, scale = strings.TrimSuffix(text, unit.suffix), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad size %q", s)
+	}
+	*b = byteSize(n * scale)
+	return nil
+}
+
+// A cidrList decodes itself from a list of networks or from a single one.
+type cidrList []*net.IPNet
+
+func (c *cidrList) UnmarshalYAML(node Node) error {
+	var texts []string
+	if _, ok := node.(Scalar); ok {
+		texts = []string{""}
+		if err := Unmarshal(node, &texts[0]); err != nil {
+			return err
+		}
+	} else if err := Unmarshal(node, &texts); err != nil {
+		return err
+	}
+	*c = nil
+	for _, text := range texts {
+		_, network, err := net.ParseCIDR(text)
+		if err != nil {
+			return err
+		}
+		*c = append(*c, network)
+	}
+	return nil
+}
+
+func (c cidrList) MarshalYAML() (Node, error) {
+	texts := make([]string, len(c))
+	for i, network := range c {
+		texts[i] = network.String()
+	}
+	return Marshal(texts)
+}
+
+type decodeFirewall struct {
+	Buffer byteSize
+	Allow  cidrList
+	Deny   *cidrList
+}
+
+func TestDecodeUnmarshaler(t *testing.T) {
+	input := "buffer: 512MiB\n" +
+		"allow:\n" +
+		"  - 10.0.0.0/8\n" +
+		"  - 192.168.1.0/24\n" +
+		"deny: 0.0.0.0/0\n"
+
+	var got decodeFirewall
+	if err := Config(input).Decode(&got); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+	if got, want := got.Buffer, byteSize(512<<20); got != want {
+		t.Errorf("buffer = %d, want %d", got, want)
+	}
+	var networks []string
+	for _, network := range got.Allow {
+		networks = append(networks, network.String())
+	}
+	if got.Deny == nil || len(*got.Deny) != 1 {
+		t.Fatalf("deny = %v, want one network", got.Deny)
+	}
+	networks = append(networks, (*got.Deny)[0].String())
+	if want := []string{"10.0.0.0/8", "192.168.1.0/24", "0.0.0.0/0"}; !reflect.DeepEqual(networks, want) {
+		t.Errorf("networks = %q, want %q", networks, want)
+	}
+}
+
+func TestDecodeUnmarshalerErrors(t *testing.T) {
+	input := "buffer: [1]\n" +
+		"allow:\n" +
+		"  - 10.0.0.0/8\n" +
+		"  - {a: b}\n" +
+		"deny: 10.0.0.0\n"
+
+	var got decodeFirewall
+	err := Config(input).Decode(&got)
+	errs, ok := err.(DecodeErrors)
+	if !ok {
+		t.Fatalf("Decode error = %#v, want DecodeErrors", err)
+	}
+
+	want := []string{
+		`yaml: 1:9: buffer: cannot decode a list into a size`,
+		`yaml: 4:5: allow[1]: cannot decode a map into string`,
+		`yaml: 5:7: deny: invalid CIDR address: 10.0.0.0`,
+	}
+	var gotErrs []string
+	for _, e := range errs {
+		gotErrs = append(gotErrs, e.Error())
+	}
+	if !reflect.DeepEqual(gotErrs, want) {
+		t.Errorf("Decode errors:\n got %q\nwant %q", gotErrs, want)
+	}
+}
+
+var decodeValueTests = []struct {
+	Input string
+	Into  interface{} // a pointer to the zero value of the type to decode into
+	Want  interface{}
+}{
+	{"42", new(int), 42},
+	{"+17", new(int64), int64(17)},
+	{"0x10", new(uint8), uint8(16)},
+	{"0755", new(int), 755},
+	{"1.5e3", new(float64), 1500.0},
+	{"7", new(float32), float32(7)},
+	{"-.inf", new(float64), math.Inf(-1)},
+	{"False", new(bool), false},
+	{"'quoted'", new(string), "quoted"},
+	{"42", new(string), "42"},
+	{"~", new(*int), (*int)(nil)},
+	{"[1, 2]", new([2]int), [2]int{1, 2}},
+	{"{1: a, 2: b}", new(map[int]string), map[int]string{1: "a", 2: "b"}},
+	{"- a\n- b\n", new(interface{}), []interface{}{"a", "b"}},
+	{"a: 1\n", new(interface{}), map[string]interface{}{"a": int64(1)}},
+	{"250ms", new(time.Duration), 250 * time.Millisecond},
+	{"2013-05-14", new(time.Time), time.Date(2013, 5, 14, 0, 0, 0, 0, time.UTC)},
+	{"1000", new(uint16), uint16(1000)},
+	{"18446744073709551615", new(uint64), uint64(math.MaxUint64)},
+	{"a: !foo\n", new(map[string]interface{}), map[string]interface{}{"a": nil}},
+	{"a: !foo\n", new(struct{ A interface{} }), struct{ A interface{} }{}},
+	{"!\n", new(interface{}), nil},
+}
+
+func TestDecodeValues(t *testing.T) {
+	for _, test := range decodeValueTests {
+		node, err := Parse(bytes.NewBufferString(test.Input))
+		if err != nil {
+			t.Errorf("parse(%q): %s", test.Input, err)
+			continue
+		}
+		if err := Unmarshal(node, test.Into); err != nil {
+			t.Errorf("Unmarshal(%q, %T): %s", test.Input, test.Into, err)
+			continue
+		}
+		if got := reflect.ValueOf(test.Into).Elem().Interface(); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Unmarshal(%q, %T) = %#v, want %#v", test.Input, test.Into, got, test.Want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	input := "name: [x]\n" +
+		"enabled: yes\n" +
+		"primary:\n" +
+		"  port: '80'\n" +
+		"  timeout: 5 minutes\n" +
+		"servers:\n" +
+		"  - port: 1e3\n" +
+		"  - host: {a: b}\n" +
+		"limits: {conns: -1, files: 8}\n" +
+		"addr: 300.1.2.3\n"
+
+	var got decodeConfig
+	err := Config(input).Decode(&got)
+	errs, ok := err.(DecodeErrors)
+	if !ok {
+		t.Fatalf("Decode error = %#v, want DecodeErrors", err)
+	}
+
+	want := []string{
+		`yaml: 1:7: name: cannot decode a list into string`,
+		`yaml: 2:10: enabled: cannot decode "yes" into bool`,
+		`yaml: 4:9: primary.port: cannot decode "80" into int`,
+		`yaml: 5:12: primary.timeout: cannot decode "5 minutes" into time.Duration: time: unknown unit " minutes" in duration "5 minutes"`,
+		`yaml: 7:11: servers[0].port: cannot decode "1e3" into int`,
+		`yaml: 8:11: servers[1].host: cannot decode a map into string`,
+		`yaml: 9:17: limits.conns: "-1" overflows uint16`,
+		`yaml: 10:7: addr: cannot decode "300.1.2.3" into net.IP: invalid IP address: 300.1.2.3`,
+	}
+	var gotErrs []string
+	for _, e := range errs {
+		gotErrs = append(gotErrs, e.Error())
+	}
+	if !reflect.DeepEqual(gotErrs, want) {
+		t.Errorf("Decode errors:\n got %q\nwant %q", gotErrs, want)
+	}
+
+	// The rest of the input is still decoded.
+	if got.Servers == nil || got.Primary == nil {
+		t.Errorf("Decode gave up after the first error: %#v", got)
+	}
+	if want := map[string]uint16{"files": 8}; !reflect.DeepEqual(got.Limits, want) {
+		t.Errorf("Limits = %v, want %v without the entry which failed", got.Limits, want)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	input := "host: a\n" +
+		"prot: 80\n" +
+		"servers:\n" +
+		"  - host: b\n" +
+		"    timeout: 1s\n" +
+		"  - hots: c\n"
+
+	type strictConfig struct {
+		Host    string
+		Servers []struct {
+			Host string
+		}
+	}
+
+	var lenient strictConfig
+	if err := Config(input).Decode(&lenient); err != nil {
+		t.Errorf("Decode: %s", err)
+	}
+
+	var got strictConfig
+	err := Config(input).DecodeStrict(&got)
+	errs, ok := err.(DecodeErrors)
+	if !ok {
+		t.Fatalf("DecodeStrict error = %#v, want DecodeErrors", err)
+	}
+	want := []string{
+		`yaml: 2:7: prot: no field of yaml.strictConfig matches the key`,
+		`yaml: 5:14: servers[0].timeout: no field of struct { Host string } matches the key`,
+		`yaml: 6:11: servers[1].hots: no field of struct { Host string } matches the key`,
+	}
+	var gotErrs []string
+	for _, e := range errs {
+		gotErrs = append(gotErrs, e.Error())
+	}
+	if !reflect.DeepEqual(gotErrs, want) {
+		t.Errorf("DecodeStrict errors:\n got %q\nwant %q", gotErrs, want)
+	}
+	if !reflect.DeepEqual(got, lenient) {
+		t.Errorf("DecodeStrict stored %#v, want %#v", got, lenient)
+	}
+
+	// Keys received by an inline map are not errors.
+	var inline decodeConfig
+	if err := Config(decodeInput).DecodeStrict(&inline); err != nil {
+		t.Errorf("DecodeStrict with an inline map: %s", err)
+	}
+}
+
+func TestDecodeBadTarget(t *testing.T) {
+	var s struct{}
+	if err := Unmarshal(Scalar("x"), s); err == nil {
+		t.Errorf("Unmarshal into a non-pointer succeeded")
+	}
+
+	var bad struct {
+		A string `yaml:"a,bogus"`
+	}
+	err := Unmarshal(Map{"a": Scalar("x")}, &bad)
+	if got, want := fmt.Sprint(err), `yaml: (root): unknown option "bogus" in the tag of struct { A string "yaml:\"a,bogus\"" }.A`; got != want {
+		t.Errorf("bad tag error = %q, want %q", got, want)
+	}
+}
@@ -17,7 +17,10 @@
 // nuanced syntaxes allowed in full-fledged YAML.  YAML does not allow indent with
 // tabs, and GYPSY does not ever consider a tab to be a space character.  It is
 // recommended that your editor be configured to convert tabs to spaces when
-// editing Gypsy config files.
+// editing Gypsy config files.  A tab in the indentation of a line, or a key or
+// list item which is not lined up with the others in its map or list, is
+// reported as a `yaml.ParseError`; a `yaml.Parser` with a Warn function reports
+// these as warnings instead and makes the best of the document.
 //
 // Gypsy understands the following to be a list:
 //
@@ -36,11 +39,15 @@
 //     foo:     bar
 //     running: away
 //
-// A mapping is an unordered list of `key:value` pairs.  All whitespace after the
-// colon is stripped from the value and is used for alignment purposes during
-// export.  If the value is not a list or a map, everything after the first
-// non-space character until the end of the line is used as the `yaml.Scalar`
-// value.
+// A mapping is a list of `key:value` pairs.  It is held in a `yaml.Map`, which
+// does not keep them in order, but a File records the order of the keys (see
+// `yaml.File.Keys`) and its Render method writes them back out in that order;
+// `yaml.File.RenderSorted` sorts them instead, as do `yaml.Keys` and
+// `yaml.Render`, which are given only the Map.  All whitespace after the
+// colon is stripped from the value; Render writes it back as it was, and
+// aligns the values of entries which were not read from the source.  If the
+// value is not a list or a map, everything after the first non-space
+// character until the end of the line is used as the `yaml.Scalar` value.
 //
 // Gypsy allows arbitrary nesting of maps inside lists, lists inside of maps, and
 // maps and/or lists nested inside of themselves.
@@ -89,6 +96,18 @@
 // indented equally.  It is allowable for the first key/value to be on the same
 // line if there is more than one key/value pair, but this is not recommended.
 //
+// Lists and maps can also be written in the short (flow) form, which may be
+// nested and may span several lines as long as the brackets are balanced.
+// Items containing commas or brackets must be quoted:
+//
+//     numbers: [one, two, three]
+//     person:  {name: John Smith, age: 42}
+//     nested:  [{a: 1}, [x, y], "c, d"]
+//     servers: [
+//       www.google.com,
+//       www.cnn.com,
+//     ]
+//
 // Values can also be expressed in long form (leading whitespace of the first line
 // is removed from it and all subsequent lines).  In the normal (baz) case,
 // newlines are treated as spaces, all indentation is removed.  In the folded case
@@ -111,6 +130,119 @@
 //       lorem ipsum
 //        dolor sit amet
 //
+// The verbatim and folded forms end at the first non-blank line which is
+// indented less than their first line; blank lines inside them are kept.  By
+// default a single trailing newline is kept; a '-' after the '|' or '>' strips
+// it and a '+' keeps every trailing blank line.  If the first line is meant to
+// start with spaces, a digit gives the indentation of the block relative to
+// its key explicitly:
+//
+//     stripped: |-
+//       no newline at the end
+//     code: |2
+//           indented(4)
+//       outdented(2)
+//
+// A stream may hold several documents, each of which starts with a "---"
+// marker (optional for the first) and may end with a "..." marker.  A "---"
+// may be preceded by directives such as "%YAML 1.2", which are skipped; a
+// "%TAG" directive is reported as an error.  Parse and the File constructors
+// read a single document; a `yaml.Decoder` returns the documents in a stream
+// one at a time, and `yaml.ReadDocuments` returns a `yaml.File` for each
+// document in a file:
+//
+//     name: web
+//     --- # the second document
+//     name: db
+//     ...
+//
+// The value of a key or list item can be named with an anchor (&name) and
+// repeated elsewhere in the same document with an alias (*name).  An alias
+// shares the Map, List or Scalar of the node it refers to.  The Render method
+// of a File writes the anchors and aliases back, while RenderSorted writes a
+// copy of the node in place of each alias.  The special key "<<" merges the
+// entries of a map, or of a list of maps, into the map which holds it;
+// entries which the map already has, or which come from an earlier map in the
+// list, take precedence:
+//
+//     base: &base
+//       host: db.example.com
+//       port: 5432
+//     prod:
+//       <<:   *base
+//       host: prod.example.com
+//
+// An alias to a node from within that node is an error, as is a document
+// whose aliases expand to more nodes than the limit set by Parser.AliasLimit.
+//
+// A node can also be given a tag, which the File it was read into records
+// and writes back out in Render.  The tags of the YAML core schema, such as
+// !!str, !!int and !!map, are checked against the node they tag, and !!str
+// keeps the typed accessors on File from converting a value.  The
+// handler given for a custom tag in the Tags of a `yaml.Parser` is called
+// with each node which has it.  `yaml.File.Tag` returns the tag of a node,
+// resolving it from the node's contents if it was not given one:
+//
+//     mode:     !!str 0755
+//     password: !secret c2VjcmV0
+//     servers:  !!seq
+//       - www.google.com
+//
+// Plain scalars are resolved by the core schema of YAML 1.2: "~" is null,
+// "true" is a boolean, "0x1F" and "1000" are integers, ".inf" is a float and
+// "2013-05-14" is a timestamp.  `yaml.Resolve` returns the kind of a node
+// and the Go value it holds, and the typed accessors on File and Decode use
+// the same rules.  A `yaml.Parser` with its Schema set to YAML11Schema reads
+// files written for YAML 1.1, in which "yes" and "off" are booleans, "0644"
+// is octal and "1_000" is an integer.
+//
+// Rather than fetching values one at a time, a whole document can be stored
+// in a Go struct with `yaml.File.Decode` or `yaml.Unmarshal`.  Keys are
+// matched to fields by their lower-cased names or by their tags, and every
+// value which does not fit its field is reported, by its path.  A type which
+// needs more than the text of a Scalar, such as a list of networks which may
+// also be written as a single one, can implement `yaml.Unmarshaler` to be
+// given the node itself:
+//
+//     type Config struct {
+//         Name    string
+//         Servers []struct {
+//             Host    string
+//             Timeout time.Duration `yaml:"timeout,omitempty"`
+//         }
+//     }
+//     var c Config
+//     err := f.Decode(&c)
+//
+// `yaml.File.DecodeStrict` and `yaml.UnmarshalStrict` also report every key
+// which matches no field, and a `yaml.Parser` with Strict set reports every
+// key which appears more than once in the same map, rather than keeping the
+// last value given for it.
+//
+// `yaml.Marshal` goes the other way, building a Node from a Go value with the
+// same tags; a type can choose its own representation by implementing
+// `yaml.Marshaler` or encoding.TextMarshaler:
+//
+//     f, err := yaml.MarshalFile(c)
+//     fmt.Print(f.Render())
+//
+// The nodes returned by `yaml.Parse` are plain `yaml.Map`, `yaml.List` and
+// `yaml.Scalar` values.  A `yaml.File` read by `yaml.ReadFile` or
+// `yaml.Parser.ParseFile` also records where each node was found (see
+// `yaml.File.Position`) and how it was written (see `yaml.File.Info`), so
+// that its Render method can write it back the same way.
+//
+// Scalars and keys may be quoted, which allows them to contain characters such
+// as ':', '#' and ',' which would otherwise be significant.  Single-quoted
+// scalars are taken literally, except that '' stands for a single quote.
+// Double-quoted scalars understand the usual backslash escapes, such as \n,
+// \t, \" and \u00e9.  A quoted scalar is always a string, so the typed
+// accessors on File will not convert "true" to a boolean:
+//
+//     "http://example.com/": 'cached: yes'
+//     greeting:              "Hello,\tworld!\n"
+//     enabled:               "true"
+//
 // The YAML subset understood by Gypsy can be expressed (loosely) in the following
 // grammar (not including comments):
 //
@@ -135,8 +267,46 @@
 //               INDENT = { ' ' }
 //
 // Any line where the first non-space character is a sharp sign (#) is a comment.
-// It will be ignored.
-// Only full-line comments are allowed.
+// A sharp sign which follows a space also starts a comment which runs to the
+// end of the line, unless it is inside a quoted scalar or a verbatim or folded
+// block.  Such a comment is removed from the value and kept in the Comment
+// which `yaml.File.Info` returns for it.  A comment ends a plain scalar, so
+// the scalar cannot continue on the lines after it:
+//
+//     port: 8080           # the value is "8080"
+//     url:  http://x/#top  # the value is "http://x/#top"
+//     tag:  "#1"           # the value is "#1"
+//
+// Comment lines and blank lines are kept too.  Those before an entry of a map
+// or list are its value's HeadComment, and those after the last entry which
+// are indented like it are the FootComment of the map or list.  The Render
+// method of a File writes them all back, along with flow collections in flow
+// style and the spacing of the source, so that a file can be read, changed
+// with SetScalar, and written with only the changed value differing:
+//
+//     f, err := yaml.ReadFile("app.yaml")
+//     ...
+//     if err := f.SetScalar("version", "1.5.0"); err != nil { ... }
+//     ioutil.WriteFile("app.yaml", []byte(f.Render()), 0644)
+//
+// RenderSorted writes none of this, only the data.  `yaml.File.Set`,
+// `yaml.File.Append` and `yaml.File.Delete` change the structure of a file,
+// creating the Maps and Lists on the way to a new node as they are needed.
+//
+// `yaml.Select` finds every node which matches a path with wildcards, slices,
+// negative indices or recursive descent, such as "servers[*].host",
+// "tags[-2:]" or "..port", along with the concrete path of each.  A filter
+// such as `servers[?(@.role == "primary")].host` keeps only the items whose
+// values compare as given, as numbers if both sides are numbers and as
+// strings otherwise.
+//
+// A key which holds '.', '[' or ']' can be written in a path in quotes, as in
+// `hosts["example.com"].port`, or with a backslash before each such character;
+// `yaml.BuildPath` writes a path from a list of keys and indices.
+// `yaml.CompilePath` parses a path once, for code which looks up the same
+// path many times, and reports a path which is not well formed up front.
+//
+// `yaml.Walk` visits every node of a tree with its path, in an order which
+// does not change from one run to the next, and `yaml.Transform` does the
+// same while replacing nodes along the way.
 package yaml
-
-// BUG(kevlar): Multi-line strings are currently not supported.
@@ -0,0 +1,291 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+)
+
+// Set puts node at the place specified by spec, using the same format as that
+// expected by Child, replacing any node already there.  Missing Maps and
+// Lists along the way are created: a key creates a Map and an index creates a
+// List.  An index may be one past the end of a List, which appends to it.
+// Set returns a NodeTypeMismatch if the path crosses a node of the wrong
+// kind, such as a key of a List, and a NodeNotFound if an index is beyond the
+// end of its List.
+//
+// An alias, or an entry a merge key gave its map, which the path passes
+// through is replaced by a copy of the node it shares, so that Set, Append,
+// Delete and SetScalar change only the node specified by spec and not the
+// anchored node or the other aliases to it.
+func (f *File) Set(spec string, node Node) error {
+	return f.edit(spec, true, func(Node, *note) (Node, *note, error) {
+		return node, nil, nil
+	})
+}
+
+// Append adds node to the end of the List specified by spec, using the same
+// format as that expected by Child.  If there is no node there, a List is
+// created for it as by Set.  Append returns a NodeTypeMismatch if the node
+// there, or one on the way to it, is of the wrong kind.
+func (f *File) Append(spec string, node Node) error {
+	full, _, _ := splitPath(spec)
+	return f.edit(spec, true, func(old Node, n *note) (Node, *note, error) {
+		if old == nil {
+			return List{node}, nil, nil
+		}
+		list, ok := old.(List)
+		if !ok {
+			return nil, nil, &NodeTypeMismatch{
+				Full:     full,
+				Spec:     full,
+				Token:    "$",
+				Expected: "yaml.List",
+				Node:     old,
+			}
+		}
+		list = append(list[:len(list):len(list)], node)
+		if n != nil {
+			n.node = list
+		}
+		return list, n, nil
+	})
+}
+
+// Delete removes the node specified by spec, using the same format as that
+// expected by Child, from its Map or List.  Later items of a List move up to
+// fill its place.  Delete returns a NodeNotFound if there is no such node,
+// and a NodeTypeMismatch if the path crosses a node of the wrong kind.
+func (f *File) Delete(spec string) error {
+	full, toks, err := splitPath(spec)
+	if err != nil {
+		return err
+	}
+	if err := checkConcrete(spec, toks); err != nil {
+		return err
+	}
+	if len(toks) == 0 {
+		f.Root, f.notes = nil, nil
+		return nil
+	}
+
+	last := toks[len(toks)-1]
+	parentSpec := full[:len(full)-len(last.text)]
+	return f.edit(parentSpec, false, func(parent Node, n *note) (Node, *note, error) {
+		if parent == nil {
+			return nil, nil, &NodeNotFound{
+				Full: full,
+				Spec: parentSpec,
+			}
+		}
+		notFound := &NodeNotFound{
+			Full: full,
+			Spec: full,
+		}
+		if err := checkKind(parent, last, full, parentSpec); err != nil {
+			return nil, nil, err
+		}
+		if isShared(n) {
+			parent, n = detach(parent, n)
+		}
+
+		switch p := parent.(type) {
+		case Map:
+			if _, ok := p[last.key]; !ok {
+				return nil, nil, notFound
+			}
+			delete(p, last.key)
+			if n != nil {
+				n.Keys = removeKey(n.Keys, last.key)
+				n.setEntry(last.key, nil)
+			}
+			return parent, n, nil
+		case List:
+			if last.index < 0 || last.index >= len(p) {
+				return nil, nil, notFound
+			}
+			list := make(List, 0, len(p)-1)
+			list = append(list, p[:last.index]...)
+			list = append(list, p[last.index+1:]...)
+			if n != nil {
+				n.node = list
+				if last.index < len(n.items) {
+					items := make([]*note, 0, len(n.items)-1)
+					items = append(items, n.items[:last.index]...)
+					n.items = append(items, n.items[last.index+1:]...)
+				}
+			}
+			return list, n, nil
+		}
+		return parent, n, nil
+	})
+}
+
+// An editFunc returns the node to put in place of node, which is described
+// by n, along with the note which describes the replacement, if any.
+type editFunc func(node Node, n *note) (Node, *note, error)
+
+// edit replaces the node specified by spec with the result of fn, which is
+// given the node there, or nil if there is none.  If create is set, missing
+// Maps and Lists on the way to it are created.
+func (f *File) edit(spec string, create bool, fn editFunc) error {
+	p, err := CompilePath(spec)
+	if err != nil {
+		return err
+	}
+	return f.editPath(p, create, fn)
+}
+
+// editPath is like edit, for a compiled path.
+func (f *File) editPath(p *Path, create bool, fn editFunc) error {
+	if p.concrete != nil {
+		return p.concrete
+	}
+	root, n, err := editNode(f.Root, f.notes.of(f.Root), false, p.full, "", p.toks, create, fn)
+	if err != nil {
+		return err
+	}
+	f.Root, f.notes = root, n
+	return nil
+}
+
+// editNode returns node, which was found at path and is described by n, with
+// the node specified by toks beneath it replaced by the result of fn, along
+// with the note which describes it.  A Map or List which is shared with
+// another part of the tree, through an alias or a merge key, is copied
+// before the path descends into it, as is every node beneath it on the path;
+// shared says whether the node which holds node was copied.
+func editNode(node Node, n *note, shared bool, full, path string, toks []pathToken, create bool, fn editFunc) (Node, *note, error) {
+	if len(toks) > 0 && isShared(n) {
+		shared = true
+	}
+	if shared {
+		node, n = detach(node, n)
+	}
+	if len(toks) == 0 {
+		return fn(node, n)
+	}
+	tok := toks[0]
+
+	if node == nil {
+		if !create {
+			return nil, nil, &NodeNotFound{
+				Full: full,
+				Spec: path,
+			}
+		}
+		if tok.kind == indexToken {
+			node = List{}
+		} else {
+			node = Map{}
+		}
+		n = &note{node: node}
+	}
+	if err := checkKind(node, tok, full, path); err != nil {
+		return nil, nil, err
+	}
+	if n == nil {
+		n = &note{node: node}
+	}
+
+	switch v := node.(type) {
+	case Map:
+		child, cn, err := editNode(v[tok.key], n.entry(v, tok.key), shared, full, path+tok.text, toks[1:], create, fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := v[tok.key]; !ok && (len(n.Keys) > 0 || len(v) == 0) {
+			n.Keys = append(n.Keys, tok.key)
+		}
+		v[tok.key] = child
+		n.setEntry(tok.key, cn)
+	case List:
+		if tok.index < 0 || tok.index > len(v) || tok.index == len(v) && !create {
+			return nil, nil, &NodeNotFound{
+				Full: full,
+				Spec: path + tok.text,
+			}
+		}
+		var old Node
+		var on *note
+		if tok.index < len(v) {
+			old, on = v[tok.index], n.item(v, tok.index)
+		}
+		child, cn, err := editNode(old, on, shared, full, path+tok.text, toks[1:], create, fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tok.index == len(v) {
+			node = append(v[:len(v):len(v)], child)
+			n.node = node
+		} else {
+			v[tok.index] = child
+		}
+		n.setItem(tok.index, cn)
+	}
+	return node, n, nil
+}
+
+// checkConcrete returns a PathError if a token of spec can specify more than
+// one node, or counts from the end of a List.
+func checkConcrete(spec string, toks []pathToken) error {
+	offset := 0
+	if spec != "" && spec[0] != '.' && spec[0] != '[' {
+		offset = -1
+	}
+	for _, tok := range toks {
+		if !tok.concrete() {
+			return &PathError{spec, offset, fmt.Sprintf("%q can only be used by Select", tok.text)}
+		}
+		offset += len(tok.text)
+	}
+	return nil
+}
+
+// checkKind returns a NodeTypeMismatch if node, which was found at path,
+// cannot hold tok.
+func checkKind(node Node, tok pathToken, full, path string) error {
+	expected := "yaml.Map"
+	if tok.kind == indexToken {
+		expected = "yaml.List"
+	}
+	switch node.(type) {
+	case Map:
+		if tok.kind != indexToken {
+			return nil
+		}
+	case List:
+		if tok.kind == indexToken {
+			return nil
+		}
+	}
+	return &NodeTypeMismatch{
+		Full:     full,
+		Spec:     path,
+		Token:    tok.text,
+		Expected: expected,
+		Node:     node,
+	}
+}
+
+// removeKey returns keys without key.
+func removeKey(keys []string, key string) []string {
+	for i, k := range keys {
+		if k == key {
+			return append(keys[:i:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,264 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const editInput = `name: web
+servers:
+  - host: a
+    port: 80
+  - host: b
+tags: [x, y]
+`
+
+var editTests = []struct {
+	Desc   string
+	Edit   func(f *File) error
+	Output string
+	Err    string
+}{
+	{
+		Desc: "set an existing scalar",
+		Edit: func(f *File) error { return f.Set("servers[1].host", Scalar("c")) },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"    port: 80\n" +
+			"  - host: c\n" +
+			"tags: [x, y]\n",
+	},
+	{
+		Desc: "set creates maps and lists",
+		Edit: func(f *File) error { return f.Set("limits.files[0].soft", Scalar("1024")) },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"    port: 80\n" +
+			"  - host: b\n" +
+			"tags: [x, y]\n" +
+			"limits:\n" +
+			"  files:\n" +
+			"    - soft: 1024\n",
+	},
+	{
+		Desc: "set one past the end appends",
+		Edit: func(f *File) error { return f.Set("servers[2].host", Scalar("c")) },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"    port: 80\n" +
+			"  - host: b\n" +
+			"  - host: c\n" +
+			"tags: [x, y]\n",
+	},
+	{
+		Desc: "set beyond the end",
+		Edit: func(f *File) error { return f.Set("servers[3].host", Scalar("c")) },
+		Err:  `yaml: .servers[3].host: ".servers[3]" not found`,
+	},
+	{
+		Desc: "set a key of a list",
+		Edit: func(f *File) error { return f.Set("servers.host", Scalar("c")) },
+		Err:  `yaml: .servers.host: type mismatch: ".servers" is yaml.List, want yaml.Map (at ".host")`,
+	},
+	{
+		Desc: "set an index of a scalar",
+		Edit: func(f *File) error { return f.Set("name[0]", Scalar("c")) },
+		Err:  `yaml: .name[0]: type mismatch: ".name" is yaml.Scalar, want yaml.List (at "[0]")`,
+	},
+	{
+		Desc: "set a bad path",
+		Edit: func(f *File) error { return f.Set("servers[x]", Scalar("c")) },
+		Err:  `yaml: bad path "servers[x]" at offset 8: invalid index "x"`,
+	},
+	{
+		Desc: "append to a flow list",
+		Edit: func(f *File) error { return f.Append("tags", Scalar("z")) },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"    port: 80\n" +
+			"  - host: b\n" +
+			"tags: [x, y, z]\n",
+	},
+	{
+		Desc: "append creates a list",
+		Edit: func(f *File) error { return f.Append("servers[0].aliases", Scalar("www")) },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"    port: 80\n" +
+			"    aliases:\n" +
+			"      - www\n" +
+			"  - host: b\n" +
+			"tags: [x, y]\n",
+	},
+	{
+		Desc: "append to a map",
+		Edit: func(f *File) error { return f.Append("servers[0]", Scalar("www")) },
+		Err:  `yaml: .servers[0]: type mismatch: ".servers[0]" is yaml.Map, want yaml.List (at "$")`,
+	},
+	{
+		Desc: "delete a key",
+		Edit: func(f *File) error { return f.Delete("servers[0].port") },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: a\n" +
+			"  - host: b\n" +
+			"tags: [x, y]\n",
+	},
+	{
+		Desc: "delete an item",
+		Edit: func(f *File) error { return f.Delete("servers[0]") },
+		Output: "name: web\n" +
+			"servers:\n" +
+			"  - host: b\n" +
+			"tags: [x, y]\n",
+	},
+	{
+		Desc: "delete a missing key",
+		Edit: func(f *File) error { return f.Delete("servers[1].port") },
+		Err:  `yaml: .servers[1].port: ".servers[1].port" not found`,
+	},
+	{
+		Desc: "delete under a missing key",
+		Edit: func(f *File) error { return f.Delete("limits.files") },
+		Err:  `yaml: .limits.files: ".limits" not found`,
+	},
+	{
+		Desc: "delete a key of a list",
+		Edit: func(f *File) error { return f.Delete("tags.x") },
+		Err:  `yaml: .tags.x: type mismatch: ".tags" is yaml.List, want yaml.Map (at ".x")`,
+	},
+}
+
+func TestEdit(t *testing.T) {
+	for _, test := range editTests {
+		f := Config(editInput)
+		err := test.Edit(f)
+		if got := fmt.Sprint(err); test.Err != "" && got != test.Err || test.Err == "" && err != nil {
+			t.Errorf("%s: error = %v, want %q", test.Desc, err, test.Err)
+			continue
+		}
+		if test.Err != "" {
+			if got := f.Render(); got != editInput {
+				t.Errorf("%s: failed edit changed the file:\n%s", test.Desc, got)
+			}
+			continue
+		}
+		if got := f.Render(); got != test.Output {
+			t.Errorf("%s:\n got %q\nwant %q", test.Desc, got, test.Output)
+		}
+	}
+}
+
+func TestSetEmptyFile(t *testing.T) {
+	f := new(File)
+	if err := f.Set("a.b", Scalar("1")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+	if err := f.Append("a.c", Scalar("2")); err != nil {
+		t.Fatalf("Append: %s", err)
+	}
+	if err := f.Set("a.a", Scalar("3")); err != nil {
+		t.Fatalf("Set: %s", err)
+	}
+	if got, want := f.Render(), "a:\n  b: 1\n  c:\n    - 2\n  a: 3\n"; got != want {
+		t.Errorf("Render:\n got %q\nwant %q", got, want)
+	}
+	if got, err := f.Get("a.c[0]"); err != nil || got != "2" {
+		t.Errorf("Get(a.c[0]) = %q, %v, want 2", got, err)
+	}
+}
+
+const sharedInput = `base: &b {host: x, db: {name: main}}
+dev: {<<: *b}
+prod: *b
+`
+
+func TestEditShared(t *testing.T) {
+	tests := []struct {
+		Desc   string
+		Edit   func(f *File) error
+		Output string
+		Base   bool // whether the edit is meant to change base
+	}{
+		{
+			Desc: "set a merged scalar",
+			Edit: func(f *File) error { return f.SetScalar("dev.host", "DEV") },
+			Output: "base: &b {host: x, db: {name: main}}\n" +
+				"dev: {<<: *b, host: DEV}\n" +
+				"prod: *b\n",
+		},
+		{
+			Desc: "set beneath a merged map",
+			Edit: func(f *File) error { return f.SetScalar("dev.db.name", "test") },
+			Output: "base: &b {host: x, db: {name: main}}\n" +
+				"dev: {<<: *b, db: {name: test}}\n" +
+				"prod: *b\n",
+		},
+		{
+			Desc: "add a key to an alias",
+			Edit: func(f *File) error { return f.Set("prod.port", Scalar("80")) },
+			Output: "base: &b {host: x, db: {name: main}}\n" +
+				"dev: {<<: *b}\n" +
+				"prod: {host: x, db: {name: main}, port: 80}\n",
+		},
+		{
+			Desc: "set beneath an alias",
+			Edit: func(f *File) error { return f.SetScalar("prod.db.name", "replica") },
+			Output: "base: &b {host: x, db: {name: main}}\n" +
+				"dev: {<<: *b}\n" +
+				"prod: {host: x, db: {name: replica}}\n",
+		},
+		{
+			Desc: "delete from an alias",
+			Edit: func(f *File) error { return f.Delete("prod.host") },
+			Output: "base: &b {host: x, db: {name: main}}\n" +
+				"dev: {<<: *b}\n" +
+				"prod: {db: {name: main}}\n",
+		},
+		{
+			Desc: "set the anchored map",
+			Edit: func(f *File) error { return f.SetScalar("base.host", "y") },
+			Output: "base: &b {host: y, db: {name: main}}\n" +
+				"dev: {host: x, db: {name: main}}\n" +
+				"prod: *b\n",
+			Base: true,
+		},
+	}
+
+	for _, test := range tests {
+		f := Config(sharedInput)
+		if err := test.Edit(f); err != nil {
+			t.Errorf("%s: %s", test.Desc, err)
+			continue
+		}
+		if got := f.Render(); got != test.Output {
+			t.Errorf("%s:\n got %q\nwant %q", test.Desc, got, test.Output)
+		}
+		if test.Base {
+			continue
+		}
+		if got, want := f.Root.(Map)["base"], Config(sharedInput).Root.(Map)["base"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: base = %v, want %v", test.Desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Marshaler is a value which can represent itself as a Node.  An error it
+// returns is reported by Marshal at the path of the value; if it is a
+// *MarshalError, as returned by calling Marshal on the value's parts, its
+// path is taken to be relative to the value.
+type Marshaler interface {
+	MarshalYAML() (Node, error)
+}
+
+// A MarshalError describes a value which could not be represented as a Node.
+type MarshalError struct {
+	Path string // the path at which the node would be, as accepted by Child
+	Err  error  // what went wrong
+}
+
+func (e *MarshalError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("yaml: %s: %s", path, e.Err)
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Marshal returns a Node representing v, which Render can write as YAML.  It
+// is the reverse of Unmarshal: structs become Maps, with their keys named by
+// the same tags, maps become Maps, and slices and arrays become Lists.
+// Struct fields with the "omitempty" option are left out if they are empty:
+// false, zero, a nil pointer or interface, or a string, slice or map of
+// length zero.  Nil pointers, maps and slices become "null".
+//
+// A value which implements Marshaler, or encoding.TextMarshaler, is replaced
+// by the Node it returns, or by a Scalar holding its text.  A time.Duration
+// is written as by its String method, and a time.Time as a timestamp.
+//
+// Render quotes the strings of the Node which would otherwise be read back
+// as different text, such as "a: b", or which YAML11Schema would read as a
+// different value than the core schema, such as "yes" or "0755".  A string
+// which both read as a number or a boolean, such as "42", is not quoted.  As
+// for any Map, Render sorts the keys, so the order of struct fields is lost.
+// MarshalFile returns a File which keeps it, and which quotes every string
+// that would be read back as something other than a string.
+func Marshal(v interface{}) (Node, error) {
+	node, _, err := marshal("", reflect.ValueOf(v))
+	return node, err
+}
+
+// MarshalFile is like Marshal, but it returns a File, whose Render method
+// writes the keys of a struct in the order of its fields and the keys of a
+// map sorted.  Strings which would otherwise be read back as something else,
+// such as "true", "yes", "42" or "a: b", are quoted.
+func MarshalFile(v interface{}) (*File, error) {
+	node, n, err := marshal("", reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return &File{Root: node, notes: n}, nil
+}
+
+// marshal returns the node representing v, along with a note which says how
+// it is to be written.
+func marshal(path string, v reflect.Value) (Node, *note, error) {
+	if !v.IsValid() {
+		return Scalar("null"), nil, nil
+	}
+	fail := func(err error) (Node, *note, error) {
+		return nil, nil, &MarshalError{Path: path, Err: err}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return Scalar("null"), nil, nil
+		}
+	}
+
+	// A method with a value receiver may be called through a pointer, so
+	// look for the interfaces before following pointers.
+	if m, ok := implementer(v, marshalerType); ok {
+		node, err := m.Interface().(Marshaler).MarshalYAML()
+		if merr, ok := err.(*MarshalError); ok {
+			return nil, nil, &MarshalError{Path: joinPath(path, merr.Path), Err: merr.Err}
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if node == nil {
+			return Scalar("null"), nil, nil
+		}
+		return node, nil, nil
+	}
+	if v.Type() == timeType || v.Type() == reflect.PtrTo(timeType) {
+		t := reflect.Indirect(v).Interface().(time.Time)
+		return Scalar(t.Format(time.RFC3339Nano)), nil, nil
+	}
+	if m, ok := implementer(v, textMarshalerType); ok {
+		text, err := m.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return fail(err)
+		}
+		node, n := stringNode(string(text))
+		return node, n, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return marshal(path, v.Elem())
+	}
+
+	if v.Type() == durationType {
+		return Scalar(time.Duration(v.Int()).String()), nil, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return marshalStruct(path, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return Scalar("null"), nil, nil
+		}
+		return marshalMap(path, v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return Scalar("null"), nil, nil
+		}
+		fallthrough
+	case reflect.Array:
+		list := make(List, v.Len())
+		n := &note{node: list}
+		for i := range list {
+			item, in, err := marshal(indexPath(path, i), v.Index(i))
+			if err != nil {
+				return nil, nil, err
+			}
+			list[i] = item
+			n.setItem(i, in)
+		}
+		return list, n, nil
+	case reflect.String:
+		node, n := stringNode(v.String())
+		return node, n, nil
+	case reflect.Bool:
+		return Scalar(strconv.FormatBool(v.Bool())), nil, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Scalar(strconv.FormatInt(v.Int(), 10)), nil, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Scalar(strconv.FormatUint(v.Uint(), 10)), nil, nil
+	case reflect.Float32, reflect.Float64:
+		return Scalar(formatFloat(v.Float(), v.Type().Bits())), nil, nil
+	}
+	return fail(fmt.Errorf("cannot marshal %s", v.Type()))
+}
+
+// implementer returns v, or a pointer to it if it is addressable, if either
+// implements the interface t.
+func implementer(v reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	if v.Type().Implements(t) {
+		return v, true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(t) {
+		return v.Addr(), true
+	}
+	return reflect.Value{}, false
+}
+
+func marshalStruct(path string, v reflect.Value) (Node, *note, error) {
+	info, err := structInfoOf(v.Type())
+	if err != nil {
+		return nil, nil, &MarshalError{Path: path, Err: err}
+	}
+
+	m := make(Map, len(info.fields))
+	n := &note{node: m}
+	n.Keys = make([]string, 0, len(info.fields))
+	for _, field := range info.fields {
+		fv := fieldByIndex(v, field.index)
+		if field.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		node, fn, err := marshal(keyPath(path, field.name), fv)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[field.name] = node
+		n.setEntry(field.name, fn)
+		n.Keys = append(n.Keys, field.name)
+	}
+
+	// The keys of an inline map follow the fields, unless a field has
+	// taken them.
+	if info.inlineMap != nil {
+		if rest := fieldByIndex(v, info.inlineMap); !rest.IsNil() {
+			extra, en, err := marshalMap(path, rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, key := range en.Keys {
+				if _, ok := m[key]; !ok {
+					m[key] = extra.(Map)[key]
+					n.setEntry(key, en.entries[key])
+					n.Keys = append(n.Keys, key)
+				}
+			}
+		}
+	}
+
+	return m, n, nil
+}
+
+func marshalMap(path string, v reflect.Value) (Node, *note, error) {
+	m := make(Map, v.Len())
+	n := &note{node: m}
+	for _, kv := range v.MapKeys() {
+		k, _, err := marshal(path, kv)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(Scalar)
+		if !ok {
+			return nil, nil, &MarshalError{Path: path, Err: fmt.Errorf("cannot use %s as a key", kv.Type())}
+		}
+		value, vn, err := marshal(keyPath(path, string(key)), v.MapIndex(kv))
+		if err != nil {
+			return nil, nil, err
+		}
+		m[string(key)] = value
+		n.setEntry(string(key), vn)
+	}
+	n.Keys = make([]string, 0, len(m))
+	for key := range m {
+		n.Keys = append(n.Keys, key)
+	}
+	sort.Strings(n.Keys)
+	return m, n, nil
+}
+
+// stringNode returns a Scalar holding s, along with a note which quotes it
+// if it would otherwise be read back as something other than the same
+// string, by the core schema or by YAML11Schema.  Render writes multi-line
+// strings as literal block scalars, which hold any text without a carriage
+// return.
+func stringNode(s string) (Node, *note) {
+	if strings.Contains(s, "\n") && !strings.Contains(s, "\r") {
+		return Scalar(s), nil
+	}
+	if resolveTag(s) != StrTag || YAML11Schema.tag(s) != StrTag || needsQuotes(s) {
+		n := &note{NodeInfo: NodeInfo{Style: DoubleQuotedStyle}, node: Scalar(s)}
+		return n.node, n
+	}
+	return Scalar(s), nil
+}
+
+// formatFloat writes f as a core schema float.
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// isEmpty reports whether v is empty for the "omitempty" option.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isEmpty(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
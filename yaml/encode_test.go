@@ -0,0 +1,209 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A point marshals itself as a list of its coordinates.
+type point struct{ X, Y int }
+
+func (p point) MarshalYAML() (Node, error) {
+	if p.X < 0 {
+		return nil, errors.New("negative point")
+	}
+	return List{Scalar(fmt.Sprint(p.X)), Scalar(fmt.Sprint(p.Y))}, nil
+}
+
+// A polyline marshals its points through Marshal.
+type polyline []point
+
+func (p polyline) MarshalYAML() (Node, error) {
+	return Marshal([]point(p))
+}
+
+var marshalTests = []struct {
+	Value  interface{}
+	Output string
+}{
+	{42, "42\n"},
+	{uint8(7), "7\n"},
+	{-1.5, "-1.5\n"},
+	{math.Inf(1), ".inf\n"},
+	{true, "true\n"},
+	{"hello", "hello\n"},
+	{"true", "\"true\"\n"},
+	{"42", "\"42\"\n"},
+	{"yes", "\"yes\"\n"},
+	{"1_000", "\"1_000\"\n"},
+	{"2013-05-14", "\"2013-05-14\"\n"},
+	{time.Date(2013, 5, 14, 9, 30, 0, 0, time.UTC), "2013-05-14T09:30:00Z\n"},
+	{"a: b", "\"a: b\"\n"},
+	{"", "\"\"\n"},
+	{"two\nlines\n", "|\n  two\n  lines\n"},
+	{nil, "null\n"},
+	{(*int)(nil), "null\n"},
+	{[]int(nil), "null\n"},
+	{[]string{}, "[]\n"},
+	{map[string]int{}, "{}\n"},
+	{[]int{1, 2}, "- 1\n- 2\n"},
+	{[2]bool{true, false}, "- true\n- false\n"},
+	{map[string]int{"b": 2, "a": 1}, "a: 1\nb: 2\n"},
+	{map[int]string{10: "x", 9: "y"}, "10: x\n9:  \"y\"\n"},
+	{90 * time.Second, "1m30s\n"},
+	{net.ParseIP("10.0.0.1"), "10.0.0.1\n"},
+	{point{1, 2}, "- 1\n- 2\n"},
+	{&point{3, 4}, "- 3\n- 4\n"},
+	{map[string]point{"p": {5, 6}}, "p:\n  - 5\n  - 6\n"},
+	{cidrList{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}}, "- 10.0.0.0/8\n"},
+	{
+		decodeServer{Host: "a", Port: 1, Timeout: time.Second},
+		"host:    a\n" +
+			"port:    1\n" +
+			"timeout: 1s\n" +
+			"w:       0\n",
+	},
+	{
+		struct {
+			Name  string `yaml:"name,omitempty"`
+			Count int    `yaml:",omitempty"`
+			Skip  bool   `yaml:"-"`
+			Tags  []string
+			Inner struct {
+				A string
+			} `yaml:"inner,omitempty"`
+		}{Name: "x", Skip: true},
+		"name: x\n" +
+			"tags: null\n",
+	},
+}
+
+func TestMarshal(t *testing.T) {
+	for _, test := range marshalTests {
+		f, err := MarshalFile(test.Value)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %s", test.Value, err)
+			continue
+		}
+		if got, want := f.Render(), test.Output; got != want {
+			t.Errorf("Marshal(%#v):\n got %q\nwant %q", test.Value, got, want)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	var in decodeConfig
+	if err := Config(decodeInput).Decode(&in); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+	in.Ignored = ""
+
+	f, err := MarshalFile(&in)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	text := f.Render()
+	if !strings.HasPrefix(text, "name:    gypsy\nenabled: true\nprimary:\n") {
+		t.Errorf("Render(Marshal) does not follow the field order:\n%s", text)
+	}
+
+	var out decodeConfig
+	if err := Config(text).Decode(&out); err != nil {
+		t.Fatalf("Decode(Render(Marshal)): %s\n%s", err, text)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip:\n got %#v\nwant %#v\nvia:\n%s", out, in, text)
+	}
+}
+
+func TestMarshalRender(t *testing.T) {
+	value := struct {
+		Hash  string
+		Yes   string
+		Octal string
+		Colon string
+		Empty string
+		Count int
+		On    bool
+		Items []string
+	}{"#not comment", "yes", "0755", "a: b", "", 3, true, []string{"no", "- x", "[a]"}}
+	node, err := Marshal(value)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	text := Render(node)
+
+	for _, schema := range []Schema{CoreSchema, YAML11Schema} {
+		p := &Parser{Schema: schema}
+		f, err := p.ParseFile(strings.NewReader(text))
+		if err != nil {
+			t.Errorf("%s: Parse(Render(Marshal)): %s\n%s", schema, err, text)
+			continue
+		}
+		if !reflect.DeepEqual(f.Root, node) {
+			t.Errorf("%s: Parse(Render(Marshal)) = %#v, want %#v\nvia:\n%s", schema, f.Root, node, text)
+		}
+		for _, key := range []string{"hash", "yes", "octal", "colon", "empty", "items[0]"} {
+			if kind, _, err := f.Resolve(key); err != nil || kind != StrTag {
+				t.Errorf("%s: Resolve(%q) = %q, %v; want %q\nvia:\n%s", schema, key, kind, err, StrTag, text)
+			}
+		}
+		if kind, _, _ := f.Resolve("count"); kind != IntTag {
+			t.Errorf("%s: Resolve(%q) = %q, want %q", schema, "count", kind, IntTag)
+		}
+		if kind, _, _ := f.Resolve("on"); kind != BoolTag {
+			t.Errorf("%s: Resolve(%q) = %q, want %q", schema, "on", kind, BoolTag)
+		}
+	}
+
+	// Scalars which were not marshaled are quoted in the same way.
+	for _, node := range []Node{
+		Map{"k": Scalar("a: b")},
+		Map{"k": Scalar(" padded")},
+		List{Scalar("- x"), Scalar("on"), Scalar("")},
+		Map{"flow": List{Scalar("a, b")}},
+	} {
+		text := Render(node)
+		if got, err := Parse(strings.NewReader(text)); err != nil || !reflect.DeepEqual(got, node) {
+			t.Errorf("Parse(Render(%#v)) = %#v, %v\nvia:\n%s", node, got, err, text)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		Value interface{}
+		Err   string
+	}{
+		{make(chan int), "yaml: (root): cannot marshal chan int"},
+		{map[string][]point{"a": {{1, 1}, {-1, 0}}}, "yaml: a[1]: negative point"},
+		{map[point]int{{1, 2}: 3}, "yaml: (root): cannot use yaml.point as a key"},
+		{map[string]polyline{"route": {{0, 0}, {1, -1}, {-2, 2}}}, "yaml: route[2]: negative point"},
+	}
+	for _, test := range tests {
+		_, err := Marshal(test.Value)
+		if got, want := fmt.Sprint(err), test.Err; got != want {
+			t.Errorf("Marshal(%#v) error = %q, want %q", test.Value, got, want)
+		}
+	}
+}
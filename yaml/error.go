@@ -0,0 +1,123 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An ErrorKind classifies the problem described by a ParseError.
+type ErrorKind int
+
+const (
+	// SyntaxError is a malformed scalar, flow collection or block header.
+	SyntaxError ErrorKind = iota + 1
+
+	// MixedNodeError is a line which continues a node as a different kind
+	// of node, such as a list item following a map key.
+	MixedNodeError
+
+	// TabError is a tab character in the indentation of a line.  Only
+	// spaces may be used to indent YAML.
+	TabError
+
+	// IndentError is a line which is not indented like the other entries
+	// of its map or list.
+	IndentError
+
+	// AliasError is an alias which refers to an unknown anchor or to a
+	// node which contains it, or which makes the document too large.
+	AliasError
+
+	// TagError is a tag which does not suit its node, such as "!!int" on
+	// a value which is not an integer, or which was rejected by the
+	// handler given for it in Parser.Tags.
+	TagError
+
+	// DuplicateKeyError is a key which appears more than once in the same
+	// map.  It is only reported by a strict Parser.
+	DuplicateKeyError
+)
+
+var errorKindNames = map[ErrorKind]string{
+	SyntaxError:    "syntax error",
+	MixedNodeError: "mixed node types",
+	TabError:       "tab in indentation",
+	IndentError:    "inconsistent indentation",
+	AliasError:     "bad alias",
+	TagError:       "bad tag",
+
+	DuplicateKeyError: "duplicate key",
+}
+
+func (kind ErrorKind) String() string {
+	if name, ok := errorKindNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(kind))
+}
+
+// A ParseError describes a problem with the YAML being parsed.
+type ParseError struct {
+	Pos  Position  // where the problem was found
+	Text string    // the offending line, as it appeared in the source
+	Kind ErrorKind // what sort of problem it is
+	Msg  string    // a description of the problem
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("yaml: %s: %s", e.Pos, e.Msg)
+}
+
+// ParseErrors is returned by a strict Parser for a document with duplicate
+// keys.  It lists each of them, in the order in which they were found.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("yaml: %d errors parsing:", len(e)))
+	for _, err := range e {
+		lines = append(lines, "\t"+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Caret returns the error message followed by the offending line, with a
+// caret under the column at which the problem was found:
+//
+//	config.yaml:3:8: invalid quoted scalar "a" b
+//	      key: "a" b
+//	           ^
+func (e *ParseError) Caret() string {
+	col := e.Pos.Column - 1
+	if col < 0 {
+		col = 0
+	}
+	return fmt.Sprintf("%s: %s\n    %s\n    %s^", e.Pos, e.Msg, e.Text, strings.Repeat(" ", col))
+}
+
+// errorAt returns a ParseError for the given (1-based) column of line.
+func errorAt(line *indentedLine, col int, kind ErrorKind, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Pos:  line.pos(col),
+		Text: line.text(),
+		Kind: kind,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
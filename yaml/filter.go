@@ -0,0 +1,272 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A filter is the expression of a [?(...)] selector, such as
+// @.role == "primary".
+type filter struct {
+	left  operand
+	op    string // "" to test whether left exists
+	right operand
+}
+
+// An operand is one side of a filter: a path relative to the node being
+// tested, or a literal.
+type operand struct {
+	isPath  bool
+	path    []pathToken
+	literal Match // for a quoted literal, with a note saying so
+}
+
+// filterOps lists the comparison operators, longest first.
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// filterEnd returns the index of the ')' which closes a filter whose
+// expression starts text, or -1 if there is none.
+func filterEnd(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"', '\'':
+			end := quotedEnd([]byte(text[i:]))
+			if end < 0 {
+				return -1
+			}
+			i += end - 1
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// parseFilter parses the expression of a filter.  On error, it also returns
+// the offset in expr at which the problem was found.
+func parseFilter(expr string) (*filter, int, error) {
+	pos := skipSpaces(expr, 0)
+	f := new(filter)
+
+	left, pos, err := parseOperand(expr, pos)
+	if err != nil {
+		return nil, pos, err
+	}
+	f.left = left
+	if pos = skipSpaces(expr, pos); pos == len(expr) {
+		if !left.isPath {
+			return nil, 0, fmt.Errorf("filter %q does not test a path", expr)
+		}
+		return f, 0, nil
+	}
+
+	for _, op := range filterOps {
+		if strings.HasPrefix(expr[pos:], op) {
+			f.op = op
+			break
+		}
+	}
+	if f.op == "" {
+		return nil, pos, fmt.Errorf("invalid operator in filter %q", expr)
+	}
+	pos = skipSpaces(expr, pos+len(f.op))
+
+	right, pos, err := parseOperand(expr, pos)
+	if err != nil {
+		return nil, pos, err
+	}
+	f.right = right
+	if pos = skipSpaces(expr, pos); pos < len(expr) {
+		return nil, pos, fmt.Errorf("unexpected %q in filter", expr[pos:])
+	}
+	return f, 0, nil
+}
+
+// parseOperand parses the operand which starts at expr[pos], and returns the
+// offset just past it.
+func parseOperand(expr string, pos int) (operand, int, error) {
+	rest := expr[pos:]
+	switch {
+	case rest == "":
+		return operand{}, pos, fmt.Errorf("missing operand in filter")
+	case rest[0] == '"' || rest[0] == '\'':
+		end := quotedEnd([]byte(rest))
+		if end < 0 {
+			return operand{}, pos, fmt.Errorf("unterminated string in filter")
+		}
+		text, style, err := unquote(rest[:end])
+		if err != nil {
+			return operand{}, pos, err
+		}
+		quoted := &note{NodeInfo: NodeInfo{Style: style}, node: Scalar(text)}
+		return operand{literal: Match{Node: quoted.node, note: quoted}}, pos + end, nil
+	}
+
+	end := operandEnd(rest)
+	if end == 0 {
+		return operand{}, pos, fmt.Errorf("missing operand in filter")
+	}
+	if rest[0] != '@' {
+		return operand{literal: Match{Node: Scalar(rest[:end])}}, pos + end, nil
+	}
+	_, toks, err := splitPath(rest[1:end])
+	if err != nil {
+		perr := err.(*PathError)
+		return operand{}, pos + 1 + perr.Offset, fmt.Errorf("%s", perr.Msg)
+	}
+	return operand{isPath: true, path: toks}, pos + end, nil
+}
+
+// operandEnd returns the length of the unquoted operand at the start of text,
+// which ends at a space or an operator outside of brackets and quotes.
+func operandEnd(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			end := quotedEnd([]byte(text[i:]))
+			if end < 0 {
+				return len(text)
+			}
+			i += end - 1
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && strings.IndexByte(" \t=!<>", c) >= 0:
+			return i
+		}
+	}
+	return len(text)
+}
+
+// skipSpaces returns the offset of the first byte of text at or after pos
+// which is not a space or a tab.
+func skipSpaces(text string, pos int) int {
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// value returns the node given by the operand for the node of m, or a Match
+// with a nil Node if a path finds nothing.
+func (o operand) value(m Match, schema Schema) Match {
+	if !o.isPath {
+		return o.literal
+	}
+	matches := selectTokens(Match{Node: m.Node, note: m.note}, o.path, schema)
+	if len(matches) == 0 {
+		return Match{}
+	}
+	return matches[0]
+}
+
+// holds reports whether the filter is true of the node of m.  The tags of
+// plain scalars are resolved according to schema.
+func (f *filter) holds(m Match, schema Schema) bool {
+	left := f.left.value(m, schema)
+	if f.op == "" {
+		return left.Node != nil
+	}
+	cmp, ok := compareScalars(left, f.right.value(m, schema), schema)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// compareScalars returns -1, 0 or 1 as the node of a is less than, equal to
+// or greater than that of b.  They are compared as numbers if both resolve to
+// one, and as strings otherwise.  It returns false unless both are Scalars.
+func compareScalars(a, b Match, schema Schema) (int, bool) {
+	as, ok := a.Node.(Scalar)
+	if !ok {
+		return 0, false
+	}
+	bs, ok := b.Node.(Scalar)
+	if !ok {
+		return 0, false
+	}
+
+	_, av, _ := resolve(as, a.note, schema)
+	_, bv, _ := resolve(bs, b.note, schema)
+	if ai, ok := av.(int64); ok {
+		if bi, ok := bv.(int64); ok {
+			switch {
+			case ai < bi:
+				return -1, true
+			case ai > bi:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if af, ok := toFloat(av); ok {
+		if bf, ok := toFloat(bv); ok {
+			return compareFloats(af, bf), true
+		}
+	}
+	return strings.Compare(string(as), string(bs)), true
+}
+
+// toFloat returns a resolved number as a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const filterInput = `servers:
+  - host: a
+    role: primary
+    port: 80
+  - host: b
+    role: replica
+    port: 8080
+    backup: true
+  - host: c
+    role: "primary"
+users:
+  ann: {age: 31, name: Ann}
+  bob: {age: 9, name: Bob}
+  cat: {age: 0x20, name: "(cat)"}
+  dan: {age: unknown, name: Dan}
+`
+
+func TestSelectFilter(t *testing.T) {
+	tests := []struct {
+		Spec string
+		Want []string
+	}{
+		{`servers[?(@.role == "primary")].host`, []string{"servers[0].host=a", "servers[2].host=c"}},
+		{`servers[?(@.role == primary)].host`, []string{"servers[0].host=a", "servers[2].host=c"}},
+		{`servers[?(@.role != 'primary')].host`, []string{"servers[1].host=b"}},
+		{`servers[?(@.port>80)].host`, []string{"servers[1].host=b"}},
+		{`servers[?(@.port >= 80)].host`, []string{"servers[0].host=a", "servers[1].host=b"}},
+		{`servers[?(@.port < "9")].host`, []string{"servers[0].host=a", "servers[1].host=b"}},
+		{`servers[?(@.backup)].host`, []string{"servers[1].host=b"}},
+		{`servers[?(@.host == @.role)]`, nil},
+		{`servers[?(@ == a)]`, nil},
+		// "unknown" is not a number, so it is compared as a string.
+		{`users[?(@.age > 30)].name`, []string{"users.ann.name=Ann", "users.cat.name=(cat)", "users.dan.name=Dan"}},
+		{`users[?(@.age <= 9.0)].name`, []string{"users.bob.name=Bob"}},
+		{`users[?(@.name == "(cat)")].age`, []string{"users.cat.age=0x20"}},
+		{`users[?(@.age == unknown)].name`, []string{"users.dan.name=Dan"}},
+		{`..[?(@.host == b)].port`, []string{"servers[1].port=8080"}},
+	}
+	root := Config(filterInput).Root
+	for _, test := range tests {
+		matches, err := Select(root, test.Spec)
+		if err != nil {
+			t.Errorf("Select(%q): %s", test.Spec, err)
+			continue
+		}
+		if got := describeMatches(matches); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Select(%q):\n got %q\nwant %q", test.Spec, got, test.Want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		Spec string
+		Err  string
+	}{
+		{`a[?(@.b == 1]`, `yaml: bad path "a[?(@.b == 1]" at offset 1: missing ")]" at the end of the filter`},
+		{`a[?(@.b == "1)]`, `yaml: bad path "a[?(@.b == \"1)]" at offset 1: missing ")]" at the end of the filter`},
+		{`a[?(@.b = 1)]`, `yaml: bad path "a[?(@.b = 1)]" at offset 8: invalid operator in filter "@.b = 1"`},
+		{`a[?(@.b == )]`, `yaml: bad path "a[?(@.b == )]" at offset 11: missing operand in filter`},
+		{`a[?(@.b[x] == 1)]`, `yaml: bad path "a[?(@.b[x] == 1)]" at offset 8: invalid index "x"`},
+		{`a[?(@.b == 1 2)]`, `yaml: bad path "a[?(@.b == 1 2)]" at offset 13: unexpected "2" in filter`},
+		{`a[?(yes)]`, `yaml: bad path "a[?(yes)]" at offset 4: filter "yes" does not test a path`},
+	}
+	for _, test := range tests {
+		_, err := Select(nil, test.Spec)
+		if got := fmt.Sprint(err); got != test.Err {
+			t.Errorf("Select(%q) error = %q, want %q", test.Spec, got, test.Err)
+		}
+	}
+}
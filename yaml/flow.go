@@ -0,0 +1,318 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"strings"
+)
+
+// isFlowStart reports whether text begins a flow collection, which is to say
+// a SHORT-MAPPING or a SHORT-SEQUENCE.
+func isFlowStart(text []byte) bool {
+	return len(text) > 0 && (text[0] == '[' || text[0] == '{')
+}
+
+// flowComplete reports whether the brackets in text are balanced, ignoring
+// any that appear inside quoted scalars.  It is used to decide whether a flow
+// collection continues onto the next line.
+func flowComplete(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote == '"' && ch == '\\':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case (ch == '"' || ch == '\'') && (i == 0 || strings.IndexByte(" [{,:", text[i-1]) >= 0):
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+// A flowSegment records the line and column from which the text of a flow
+// collection starting at the given offset was read.
+type flowSegment struct {
+	start int
+	line  *indentedLine
+	col   int
+}
+
+// parseFlow parses text, which must consist of exactly one flow collection,
+// into a List or a Map.  The text was read from the given segments, which
+// are used to find the position of each node.
+func (ps *parser) parseFlow(text string, segments []flowSegment) (*note, error) {
+	p := &flowParser{parser: ps, text: text, segments: segments}
+	node, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, p.errorf("unexpected %q after flow collection", p.text[p.pos:])
+	}
+	return node, nil
+}
+
+type flowParser struct {
+	parser   *parser // for anchors and aliases
+	text     string
+	pos      int
+	segments []flowSegment
+}
+
+// locate returns the line and column from which offset was read.
+func (p *flowParser) locate(offset int) (*indentedLine, int) {
+	seg := p.segments[0]
+	for _, s := range p.segments[1:] {
+		if s.start > offset {
+			break
+		}
+		seg = s
+	}
+	return seg.line, seg.col + offset - seg.start
+}
+
+// annotate returns a note for a node which started at the given offset.
+func (p *flowParser) annotate(node Node, start int) *note {
+	line, col := p.locate(start)
+	return newNote(node, line.pos(col))
+}
+
+func (p *flowParser) errorf(format string, args ...interface{}) error {
+	line, col := p.locate(p.pos)
+	return errorAt(line, col, SyntaxError, format, args...)
+}
+
+// name reads the name of an anchor or alias, including its '&' or '*'.
+func (p *flowParser) name() string {
+	name, rest := anchorName([]byte(p.text[p.pos:]))
+	p.pos = len(p.text) - len(rest)
+	return name
+}
+
+func (p *flowParser) skipSpace() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *flowParser) peek() byte {
+	if p.pos >= len(p.text) {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+// value parses a SHORT-OBJECT: a nested collection or a scalar, either of
+// which may be named by an anchor and given a tag, or an alias.
+func (p *flowParser) value() (*note, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '&':
+		name := p.name()
+		if name == "" {
+			return nil, p.errorf("missing anchor name")
+		}
+		p.parser.defineAnchor(name)
+		node, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return p.parser.setAnchor(name, node), nil
+	case '*':
+		line, col := p.locate(p.pos)
+		return p.parser.alias(line, col, p.name())
+	case '!':
+		line, col := p.locate(p.pos)
+		tag, rest := tagName([]byte(p.text[p.pos:]))
+		p.pos = len(p.text) - len(rest)
+		var node *note
+		if ch := p.peek(); ch != ',' && ch != ']' && ch != '}' && ch != 0 {
+			var err error
+			if node, err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		return p.parser.applyTag(line, col, tag, node)
+	case '[':
+		return p.sequence()
+	case '{':
+		return p.mapping()
+	}
+	start := p.pos
+	s, style, err := p.scalar()
+	if err != nil {
+		return nil, err
+	}
+	node := p.annotate(Scalar(s), start)
+	node.Style = style
+	return node, nil
+}
+
+func (p *flowParser) sequence() (*note, error) {
+	node := p.annotate(nil, p.pos)
+	node.Style = FlowStyle
+	p.pos++ // '['
+	list := make(List, 0)
+	for {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			node.node = list
+			return node, nil
+		}
+
+		start := p.pos
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+
+		// A single "key: value" pair inside a sequence is a one-entry map.
+		if p.skipSpace(); p.peek() == ':' {
+			key, ok := item.value().(Scalar)
+			if !ok {
+				return nil, p.errorf("collections cannot be used as keys")
+			}
+			p.pos++
+			val, err := p.entryValue()
+			if err != nil {
+				return nil, err
+			}
+			m := p.annotate(make(Map), start)
+			m.Style = FlowStyle
+			if err := setKey(m, string(key), val); err != nil {
+				return nil, p.errorf("%s", err)
+			}
+			m.setKeyStyle(string(key), item.Style)
+			item = m
+		}
+		node.setItem(len(list), item)
+		list = append(list, item.value())
+
+		if err := p.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *flowParser) mapping() (*note, error) {
+	m := make(Map)
+	node := p.annotate(m, p.pos)
+	node.Style = FlowStyle
+	p.pos++ // '{'
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return node, nil
+		}
+
+		if ch := p.peek(); ch == '[' || ch == '{' {
+			return nil, p.errorf("collections cannot be used as keys")
+		}
+		start := p.pos
+		key, style, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+
+		val := p.annotate(Scalar(""), p.pos)
+		if p.skipSpace(); p.peek() == ':' {
+			p.pos++
+			if val, err = p.entryValue(); err != nil {
+				return nil, err
+			}
+		}
+		line, col := p.locate(start)
+		p.parser.checkKey(node, key, line, col)
+		if err := setKey(node, key, val); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		node.setKeyStyle(key, style)
+
+		if err := p.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// entryValue parses the value following the ':' of a flow mapping entry,
+// which may be empty.
+func (p *flowParser) entryValue() (*note, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case ',', ']', '}':
+		return p.annotate(Scalar(""), p.pos), nil
+	}
+	return p.value()
+}
+
+// separator consumes the ',' between entries, or leaves the closing
+// bracket in place for the caller to consume.
+func (p *flowParser) separator(end byte) error {
+	p.skipSpace()
+	switch ch := p.peek(); ch {
+	case ',':
+		p.pos++
+		return nil
+	case end:
+		return nil
+	case 0:
+		return p.errorf("missing %q at end of flow collection", end)
+	default:
+		return p.errorf("unexpected %q in flow collection, want ',' or %q", ch, end)
+	}
+}
+
+// scalar reads a SHORT-SCALAR.  Quoted scalars may contain any character
+// and are decoded; plain scalars end at a flow indicator or at a ':' which
+// is followed by a space or another indicator.
+func (p *flowParser) scalar() (string, Style, error) {
+	start := p.pos
+	if rest := []byte(p.text[start:]); isQuoted(rest) {
+		n := quotedEnd(rest)
+		if n < 0 {
+			return "", PlainStyle, p.errorf("unterminated quoted scalar")
+		}
+		p.pos += n
+		s, style, err := unquote(p.text[start:p.pos])
+		if err != nil {
+			p.pos = start
+			return "", PlainStyle, p.errorf("%s", err)
+		}
+		return s, style, nil
+	}
+
+	for ; p.pos < len(p.text); p.pos++ {
+		switch p.text[p.pos] {
+		case ',', '[', ']', '{', '}':
+			return strings.TrimRight(p.text[start:p.pos], " "), PlainStyle, nil
+		case ':':
+			if p.pos+1 == len(p.text) || strings.IndexByte(" ,[]{}", p.text[p.pos+1]) >= 0 {
+				return strings.TrimRight(p.text[start:p.pos], " "), PlainStyle, nil
+			}
+		}
+	}
+	return strings.TrimRight(p.text[start:], " "), PlainStyle, nil
+}
@@ -17,34 +17,98 @@ package yaml
 import (
 	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
 	"strings"
 )
 
 // Parse returns a root-level Node parsed from the lines read from r.  In
-// general, this will be done for you by one of the File constructors.
+// general, this will be done for you by one of the File constructors, which
+// also keep the position of each node and the details of how it was written.
+// Problems with the YAML itself are reported as a *ParseError.
+//
+// A Map does not record the order of its keys, so Keys and Render of the
+// node returned by Parse give them sorted.  To keep the order of the source,
+// parse a File with Parser.ParseFile, and use File.Keys and File.Render.
 func Parse(r io.Reader) (node Node, err error) {
-	lb := &lineBuffer{
-		Reader: bufio.NewReader(r),
+	return new(Parser).Parse(r)
+}
+
+// A Parser holds the options with which YAML is parsed.  The zero Parser
+// behaves just like the Parse function.
+type Parser struct {
+	// Filename is recorded in the positions of the parsed nodes and of any
+	// errors.
+	Filename string
+
+	// Warn, if set, makes the parser lenient.  Problems which do not stop
+	// the document from being understood, such as tabs in the indentation
+	// or keys which are not lined up with their siblings, are passed to
+	// Warn instead of ending the parse.
+	Warn func(*ParseError)
+
+	// AliasLimit is the largest number of nodes the aliases in a document
+	// may expand to, counting each alias as a copy of the node it refers
+	// to.  It protects against small documents which expand to enormous
+	// trees.  If it is zero, DefaultAliasLimit is used; if it is negative,
+	// there is no limit.
+	AliasLimit int
+
+	// Strict, if set, makes a key which appears more than once in the same
+	// map an error.  Otherwise the last value given for the key is kept.
+	// Every duplicate key in a document is reported, in a ParseErrors.
+	Strict bool
+
+	// Schema is the schema by which the tags of plain scalars are resolved;
+	// it is recorded in the File read by ParseFile.  The default is the core
+	// schema of YAML 1.2.  YAML11Schema reads files written for YAML 1.1,
+	// in which "yes" and "no" are booleans and "0755" is octal.
+	Schema Schema
+
+	// Tags holds the handler for each custom tag, such as "!secret", which
+	// is called with every node given that tag.
+	Tags map[string]TagHandler
+}
+
+// Parse returns a root-level Node parsed from the lines read from r, which,
+// like that of the Parse function, does not keep the order of its keys.  The
+// input must hold a single document, though it may begin with a "---" marker
+// and end with a "..." marker; use a Decoder to read a stream of documents.
+func (p *Parser) Parse(r io.Reader) (node Node, err error) {
+	f, err := p.ParseFile(r)
+	if err != nil {
+		return nil, err
 	}
+	return f.Root, nil
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			switch r := r.(type) {
-			case error:
-				err = r
-			case string:
-				err = errors.New(r)
-			default:
-				err = fmt.Errorf("%v", r)
-			}
-		}
-	}()
+// ParseFile is like Parse, but it returns a File, which records the position
+// of each node and how it was written: its style, comments, tag and anchor.
+// See File.Info.
+func (p *Parser) ParseFile(r io.Reader) (*File, error) {
+	d := p.NewDecoder(r)
+	f, err := d.NextFile()
+	if err == io.EOF {
+		return &File{schema: p.Schema}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	node = parseNode(lb, 0, nil)
-	return
+	// Any further documents must be empty.
+	for {
+		extra, err := d.Next()
+		if err == io.EOF {
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if extra != nil {
+			return nil, errorAt(d.start, 1, SyntaxError,
+				"more than one document in stream")
+		}
+	}
 }
 
 // Supporting types and constants
@@ -54,20 +118,66 @@ const (
 	typSequence
 	typMapping
 	typScalar
+	typFlow
+	typBlock
+	typQuoted
+	typAlias
 )
 
 var typNames = []string{
-	"Unknown", "Sequence", "Mapping", "Scalar",
+	"Unknown", "Sequence", "Mapping", "Scalar", "Flow", "Block", "Quoted", "Alias",
 }
 
 type lineReader interface {
 	Next(minIndent int) *indentedLine
+	Raw() *indentedLine
+	Unread(line *indentedLine)
+
+	// Head returns the comments and blank lines skipped by Next since the
+	// last call to Head or Foot, which precede the line it returned.
+	Head() string
+
+	// Foot returns the comments skipped by Next which are indented by at
+	// least min spaces, along with any blank lines between them, leaving
+	// the rest for Head.
+	Foot(min int) string
 }
 
 type indentedLine struct {
-	lineno int
-	indent int
-	line   []byte
+	filename string
+	lineno   int
+	offset   int // columns before the indentation, as after a "---" marker
+	indent   int
+	line     []byte
+	source   string // the whole line, as it appeared in the source
+
+	// tabbed is set for a line whose indentation holds a tab.  Only a
+	// lenient parser reads such a line, at the indentation it expects.
+	tabbed bool
+}
+
+// pos returns the position of the given (1-based) column of the line.
+func (line *indentedLine) pos(col int) Position {
+	return Position{
+		Filename: line.filename,
+		Line:     line.lineno + 1,
+		Column:   line.offset + col,
+	}
+}
+
+// text returns the line as it appeared in the source.
+func (line *indentedLine) text() string {
+	if line.source == "" {
+		return strings.Repeat(" ", line.offset+line.indent) + string(line.line)
+	}
+	return line.source
+}
+
+// blank reports whether the line holds nothing but whitespace, which may
+// include tabs, and perhaps a comment.
+func (line *indentedLine) blank() bool {
+	content := bytes.TrimLeft(line.line, " \t")
+	return len(content) == 0 || content[0] == '#'
 }
 
 func (line *indentedLine) String() string {
@@ -75,13 +185,252 @@ func (line *indentedLine) String() string {
 		strings.Repeat(" ", 0*line.indent), string(line.line))
 }
 
-func parseNode(r lineReader, ind int, initial Node) (node Node) {
+// A parser builds a tree of nodes from the lines it reads.
+type parser struct {
+	lines      lineReader
+	warn       func(*ParseError)     // see Parser.Warn
+	aliasLimit int                   // see Parser.AliasLimit
+	strict     bool                  // see Parser.Strict
+	schema     Schema                // see Parser.Schema
+	tags       map[string]TagHandler // see Parser.Tags, by normalized tag
+
+	// The anchors of the current document, and the expanded size of each
+	// alias to them.
+	anchors  map[string]*anchor
+	aliases  map[*note]int
+	expanded int // the number of nodes added by aliases so far
+
+	// In strict mode, the keys given to each map of the current document,
+	// and those which were given more than once.
+	keys       map[*note]map[string]bool
+	duplicates []duplicate
+}
+
+// A duplicate is a key which was given more than once to a map.
+type duplicate struct {
+	m   *note
+	key string
+	err *ParseError
+}
+
+// problem reports a problem from which the parser can recover.  Unless the
+// parser is lenient, the problem is returned as an error.
+func (p *parser) problem(err *ParseError) error {
+	if p.warn == nil {
+		return err
+	}
+	p.warn(err)
+	return nil
+}
+
+// checkKey records that key was given on line to the map n describes, noting
+// it as a duplicate if it was given before.  Keys added by a merge are not
+// recorded, so they may be overridden.  It does nothing unless the parser is
+// strict.
+func (p *parser) checkKey(n *note, key string, line *indentedLine, col int) {
+	if !p.strict {
+		return
+	}
+	if p.keys == nil {
+		p.keys = make(map[*note]map[string]bool)
+	}
+	seen := p.keys[n]
+	if seen == nil {
+		seen = make(map[string]bool)
+		p.keys[n] = seen
+	}
+	if seen[key] {
+		p.duplicates = append(p.duplicates, duplicate{
+			m:   n,
+			key: key,
+			err: errorAt(line, col, DuplicateKeyError, "duplicate key %q", key),
+		})
+	}
+	seen[key] = true
+}
+
+// duplicateErrors returns the duplicate keys found in the document with the
+// given root, described by their paths, and forgets the document's keys.
+func (p *parser) duplicateErrors(root *note) error {
+	dups := p.duplicates
+	p.keys, p.duplicates = nil, nil
+	if len(dups) == 0 {
+		return nil
+	}
+
+	paths := make(map[*note]string)
+	var walk func(path string, n *note)
+	walk = func(path string, n *note) {
+		if n == nil {
+			return
+		}
+		if _, ok := paths[n]; ok {
+			return
+		}
+		paths[n] = path
+		for _, key := range n.Keys {
+			walk(keyPath(path, key), n.entries[key])
+		}
+		for i, item := range n.items {
+			walk(indexPath(path, i), item)
+		}
+	}
+	walk("", root)
+
+	errs := make(ParseErrors, len(dups))
+	for i, dup := range dups {
+		if path, ok := paths[dup.m]; ok {
+			dup.err.Msg = fmt.Sprintf("duplicate key %s", keyPath(path, dup.key))
+		}
+		errs[i] = dup.err
+	}
+	return errs
+}
+
+// An inline is one of the nodes found on a single line, such as each of the
+// keys and the value in "a: b: c".
+type inline struct {
+	typ   int
+	piece string // the key, the text of the value, or the alias name
+	col   int    // the column at which it starts
+
+	// For keys and list items, the column just past the ':' or '-' and the
+	// column at which the value starts, if it is on the same line.
+	end      int
+	valueCol int
+
+	// For keys and list items, the anchor which names the value and the
+	// tag given to it, with the column at which the tag starts.
+	anchor string
+	tag    string
+	tagCol int
+
+	// For block scalars, whether they are literal or folded, and for keys,
+	// whether they were quoted.
+	style Style
+
+	// For flow collections, the lines on which the text was found.
+	flow []flowSegment
+}
+
+// inlines splits the content of a line into its inline nodes, reading
+// further lines if the last of them is a flow collection or block scalar.
+// Comments found on further lines are added to comment.
+func (p *parser) inlines(line *indentedLine, partial []byte, col int, comment *string) ([]inline, error) {
+	var found []inline
+	for {
+		// The value of a key or list item may be named by an anchor and
+		// given a tag, in either order.
+		for len(found) > 0 && len(partial) > 0 && (partial[0] == '&' || partial[0] == '!') {
+			parent := &found[len(found)-1]
+			var rest []byte
+			if partial[0] == '!' {
+				parent.tag, rest = tagName(partial)
+				parent.tagCol = col
+			} else {
+				var name string
+				if name, rest = anchorName(partial); name == "" {
+					return nil, errorAt(line, col, SyntaxError, "missing anchor name")
+				}
+				parent.anchor = name
+				p.defineAnchor(name)
+			}
+			partial, col = rest, col+len(partial)-len(rest)
+		}
+
+		vtyp, brk := getType(partial)
+		begin, end := partial[:brk], partial[brk:]
+
+		if vtyp == typMapping {
+			end = end[1:]
+		}
+		end = bytes.TrimLeft(end, " ")
+		endcol := col + len(partial) - len(end)
+
+		switch vtyp {
+		case typUnknown:
+			return found, nil
+		case typScalar:
+			value := inline{typ: typScalar, piece: string(end), col: endcol}
+			switch {
+			case isFlowStart(end):
+				value.typ = typFlow
+				value.flow = []flowSegment{{line: line, col: endcol}}
+				for !flowComplete(value.piece) {
+					l := p.lines.Next(0)
+					if l == nil {
+						break
+					}
+					more, c := splitComment(l.line)
+					value.piece += " "
+					value.flow = append(value.flow, flowSegment{
+						start: len(value.piece),
+						line:  l,
+						col:   l.indent + 1,
+					})
+					value.piece += string(more)
+					*comment = joinComments(*comment, c)
+				}
+			case isQuoted(end):
+				if n := quotedEnd(end); n < 0 || len(bytes.TrimRight(end[n:], " ")) > 0 {
+					return nil, errorAt(line, endcol, SyntaxError, "invalid quoted scalar %s", end)
+				}
+				value.typ = typQuoted
+				value.piece = string(bytes.TrimRight(end, " "))
+			case end[0] == '*':
+				name, rest := anchorName(end)
+				if len(rest) > 0 {
+					return nil, errorAt(line, endcol+len(end)-len(rest), SyntaxError,
+						"unexpected %q after alias", rest)
+				}
+				value.typ = typAlias
+				value.piece = name
+			case isBlockHeader(end):
+				text, err := readBlockScalar(p.lines, string(end), line.indent)
+				if err != nil {
+					return nil, errorAt(line, endcol, SyntaxError, "%s", err)
+				}
+				value.typ = typBlock
+				value.piece = text
+				value.style = LiteralStyle
+				if end[0] == '>' {
+					value.style = FoldedStyle
+				}
+			}
+			return append(found, value), nil
+		case typMapping:
+			key, style := strings.TrimSpace(string(begin)), PlainStyle
+			if isQuoted(begin) {
+				var err error
+				if key, style, err = unquote(key); err != nil {
+					return nil, errorAt(line, col, SyntaxError, "%s", err)
+				}
+			}
+			found = append(found, inline{typ: typMapping, piece: key, col: col, end: col + brk + 1, valueCol: endcol, style: style})
+		case typSequence:
+			found = append(found, inline{typ: typSequence, piece: "-", col: col, end: col + 1, valueCol: endcol})
+		}
+		partial, col = end, endcol
+	}
+}
+
+// parseNode reads the lines indented by at least min spaces into the node
+// which initial describes, which is nil unless the node was started on an
+// earlier line.  The lines of a map or list must all be indented equally.
+func (p *parser) parseNode(min int, initial *note) (node *note, err error) {
 	first := true
 	node = initial
+	ind := min
 
 	// read lines
 	for {
-		line := r.Next(ind)
+		// At the top level, there is no enclosing node to return a line
+		// which is indented less than the first to, so read it here.
+		next := ind
+		if min == 0 {
+			next = 0
+		}
+		line := p.lines.Next(next)
 		if line == nil {
 			break
 		}
@@ -90,148 +439,338 @@ func parseNode(r lineReader, ind int, initial Node) (node Node) {
 			continue
 		}
 
-		if first {
-			ind = line.indent
-			first = false
+		_, isScalar := node.value().(Scalar)
+
+		// A key or list item indented further than the value before it is
+		// not a continuation of that value; leave it for the enclosing map
+		// or list to report.
+		if first && isScalar && min > 0 {
+			switch typ, _ := getType(bytes.TrimLeft(line.line, " \t")); typ {
+			case typSequence, typMapping:
+				p.lines.Unread(line)
+				return node, nil
+			}
 		}
 
-		types := []int{}
-		pieces := []string{}
+		// The entries of a map or list must line up, including the rest
+		// of one started on an earlier line.
+		want := ind
+		if first {
+			want = -1
+			if initial != nil && initial.Pos.IsValid() && !isScalar {
+				want = initial.Pos.Column - 1
+			}
+		}
 
-		var inlineValue func([]byte)
-		inlineValue = func(partial []byte) {
-			// TODO(kevlar): This can be a for loop now
-			vtyp, brk := getType(partial)
-			begin, end := partial[:brk], partial[brk:]
+		if line.tabbed {
+			if line.line[0] == '\t' {
+				err := errorAt(line, line.indent+1, TabError, "tab character in indentation")
+				if err := p.problem(err); err != nil {
+					return nil, err
+				}
+			}
+			// The width of a tab is unknown, so the line is taken to
+			// be lined up with the node being read.
+			indent := want
+			if indent < 0 {
+				indent = min
+			}
+			trimmed := bytes.TrimLeft(line.line, " \t")
+			line.offset += line.indent + len(line.line) - len(trimmed) - indent
+			line.indent, line.line = indent, trimmed
+		}
 
-			if vtyp == typMapping {
-				end = end[1:]
+		if want >= 0 && !isScalar && line.indent != want {
+			if err := p.misaligned(line, want); err != nil {
+				return nil, err
 			}
-			end = bytes.TrimLeft(end, " ")
+		}
 
-			switch vtyp {
-			case typScalar:
-				types = append(types, typScalar)
-				pieces = append(pieces, string(end))
-				return
-			case typMapping:
-				types = append(types, typMapping)
-				pieces = append(pieces, strings.TrimSpace(string(begin)))
-
-				trimmed := bytes.TrimSpace(end)
-				if len(trimmed) == 1 && trimmed[0] == '|' {
-					text := ""
-
-					for {
-						l := r.Next(1)
-						if l == nil {
-							break
-						}
-
-						s := string(l.line)
-						s = strings.TrimSpace(s)
-						if len(s) == 0 {
-							break
-						}
-						text = text + "\n" + s
-					}
+		if first {
+			ind = line.indent
+			first = false
+		}
 
-					types = append(types, typScalar)
-					pieces = append(pieces, string(text))
-					return
-				}
-				inlineValue(end)
-			case typSequence:
-				types = append(types, typSequence)
-				pieces = append(pieces, "-")
-				inlineValue(end)
-			}
+		// Comments and blank lines before the line belong to the entry
+		// which starts on it.
+		head := p.lines.Head()
+
+		content, comment := splitComment(line.line)
+		space := len(line.line) - len(content) - len(comment)
+		inlines, err := p.inlines(line, content, line.indent+1, &comment)
+		if err != nil {
+			return nil, err
 		}
 
-		inlineValue(line.line)
-		var prev Node
+		var prev *note
+		var prevComplete bool
 
 		// Nest inlines
-		for len(types) > 0 {
-			last := len(types) - 1
-			typ, piece := types[last], pieces[last]
+		for last := len(inlines) - 1; last >= 0; last-- {
+			in := inlines[last]
+			pos := line.pos(in.col)
 
-			var current Node
+			var current *note
 			if last == 0 {
 				current = node
 			}
-			//child := parseNode(r, line.indent+1, typUnknown) // TODO allow scalar only
 
 			// Add to current node
-			switch typ {
+			switch in.typ {
 			case typScalar: // last will be == nil
-				if _, ok := current.(Scalar); current != nil && !ok {
-					panic("cannot append scalar to non-scalar node")
+				if current == nil {
+					current = newNote(Scalar(in.piece), pos)
+					break
+				}
+				scalar, ok := current.node.(Scalar)
+				if !ok {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot append scalar to %s", nodeKind(current.node))
+				}
+				// A comment ends a plain scalar, whether it follows
+				// the scalar's last line or sits on a line of its own.
+				if current.Comment != "" || strings.Contains(head, "#") {
+					return nil, errorAt(line, in.col, SyntaxError,
+						"scalar continues after a comment")
 				}
+				current.node = scalar + " " + Scalar(in.piece)
+			case typFlow:
 				if current != nil {
-					current = Scalar(piece) + " " + current.(Scalar)
-					break
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot append flow collection to %s", nodeKind(current.node))
+				}
+				if current, err = p.parseFlow(in.piece, in.flow); err != nil {
+					return nil, err
+				}
+			case typBlock:
+				if current != nil {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot append block scalar to %s", nodeKind(current.node))
+				}
+				current = newNote(Scalar(in.piece), pos)
+				current.Style = in.style
+			case typQuoted:
+				if current != nil {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot append quoted scalar to %s", nodeKind(current.node))
+				}
+				text, style, err := unquote(in.piece)
+				if err != nil {
+					return nil, errorAt(line, in.col, SyntaxError, "%s", err)
+				}
+				current = newNote(Scalar(text), pos)
+				current.Style = style
+			case typAlias:
+				if current != nil {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot append alias to %s", nodeKind(current.node))
+				}
+				if current, err = p.alias(line, in.col, in.piece); err != nil {
+					return nil, err
 				}
-				current = Scalar(piece)
 			case typMapping:
-				var mapNode Map
-				var ok bool
-				var child Node
+				var child *note
 
 				// Get the current map, if there is one
-				if mapNode, ok = current.(Map); current != nil && !ok {
-					_ = current.(Map) // panic
+				if _, ok := current.value().(Map); current != nil && !ok {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot add key %q to %s", in.piece, nodeKind(current.node))
 				} else if current == nil {
-					mapNode = make(Map)
+					current = newNote(make(Map), pos)
 				}
 
-				if _, inlineMap := prev.(Scalar); inlineMap && last > 0 {
-					current = Map{
-						piece: prev,
+				// Flow collections, block scalars and aliases are
+				// complete on their own, so there is nothing more to
+				// read for them.
+				_, inlineMap := prev.value().(Scalar)
+				if child = prev; (!inlineMap || last == 0) && !prevComplete {
+					if child, err = p.parseNode(line.indent+1, prev); err != nil {
+						return nil, err
 					}
-					break
 				}
-
-				child = parseNode(r, line.indent+1, prev)
-				mapNode[piece] = child
-				current = mapNode
+				if child, err = p.properties(line, in, child); err != nil {
+					return nil, err
+				}
+				if prev == nil {
+					child = annotate(child, comment, space)
+				}
+				child = entryLayout(line, in, prev != nil, child)
+				if last == 0 {
+					child = addHead(child, head)
+				}
+				p.checkKey(current, in.piece, line, in.col)
+				if err := setKey(current, in.piece, child); err != nil {
+					return nil, errorAt(line, in.col, SyntaxError, "%s", err)
+				}
+				current.setKeyStyle(in.piece, in.style)
 
 			case typSequence:
-				var listNode List
-				var ok bool
-				var child Node
+				var child *note
 
 				// Get the current list, if there is one
-				if listNode, ok = current.(List); current != nil && !ok {
-					_ = current.(List) // panic
+				listNode, ok := current.value().(List)
+				if current != nil && !ok {
+					return nil, errorAt(line, in.col, MixedNodeError,
+						"cannot add list item to %s", nodeKind(current.node))
 				} else if current == nil {
 					listNode = make(List, 0)
+					current = newNote(listNode, pos)
 				}
 
-				if _, inlineList := prev.(Scalar); inlineList && last > 0 {
-					current = List{
-						prev,
+				_, inlineList := prev.value().(Scalar)
+				if child = prev; (!inlineList || last == 0) && !prevComplete {
+					if child, err = p.parseNode(line.indent+1, prev); err != nil {
+						return nil, err
 					}
-					break
 				}
+				if child, err = p.properties(line, in, child); err != nil {
+					return nil, err
+				}
+				if prev == nil {
+					child = annotate(child, comment, space)
+				}
+				child = entryLayout(line, in, prev != nil, child)
+				if last == 0 {
+					child = addHead(child, head)
+				}
+				current.node = append(listNode, child.value())
+				current.setItem(len(listNode), child)
 
-				child = parseNode(r, line.indent+1, prev)
-				listNode = append(listNode, child)
-				current = listNode
+			}
 
+			// The comment belongs to the innermost value on the line.
+			if last == len(inlines)-1 && in.typ != typMapping && in.typ != typSequence {
+				current = annotate(current, comment, space)
+			}
+			if last == 0 && in.typ != typMapping && in.typ != typSequence {
+				current = addHead(current, head)
 			}
 
-			if last < 0 {
-				last = 0
+			switch in.typ {
+			case typFlow, typBlock, typQuoted, typAlias:
+				prevComplete = true
+			default:
+				prevComplete = false
 			}
-			types = types[:last]
-			pieces = pieces[:last]
 			prev = current
 		}
 
 		node = prev
 	}
-	return
+
+	// The comments after the last entry of a map or list which are indented
+	// like its entries, or at the end of the document, follow it.
+	switch node.value().(type) {
+	case Map, List:
+		node.FootComment += p.lines.Foot(ind)
+	}
+	return node, nil
+}
+
+// entryLayout records how the value of a key or list item, described by n,
+// was laid out relative to it: the spaces between the ':' or '-' and a value
+// on the same line, or how far a map or list on the following lines was
+// indented.
+func entryLayout(line *indentedLine, in inline, sameLine bool, n *note) *note {
+	if n == nil {
+		return nil
+	}
+	switch {
+	case n.Pos.Line > line.lineno+1:
+		// A key which follows another key, a document marker or a tag on
+		// its line may be further right than the entries which belong to
+		// it.  Those are written indented in the usual way.
+		if indent := n.Pos.Column - line.pos(in.col).Column; indent > 0 {
+			n.layout.indent = indent
+			n.layout.indented = true
+		}
+	case sameLine || in.tag != "":
+		n.layout.valueSpace = in.valueCol - in.end
+	}
+	return n
+}
+
+// addHead attaches the comments and blank lines before the node n describes.
+func addHead(n *note, head string) *note {
+	if n == nil || head == "" {
+		return n
+	}
+	n.HeadComment = head + n.HeadComment
+	return n
+}
+
+// properties gives the value of a key or list item the tag and anchor which
+// were written before it.
+func (p *parser) properties(line *indentedLine, in inline, value *note) (*note, error) {
+	if in.tag != "" {
+		var err error
+		if value, err = p.applyTag(line, in.tagCol, in.tag, value); err != nil {
+			return nil, err
+		}
+	}
+	if in.anchor != "" {
+		value = p.setAnchor(in.anchor, value)
+	}
+	return value, nil
+}
+
+// misaligned reports a line which is not indented by want spaces like the
+// other entries of its map or list.
+func (p *parser) misaligned(line *indentedLine, want int) error {
+	return p.problem(errorAt(line, line.indent+1, IndentError,
+		"inconsistent indentation: found %d spaces, want %d", line.indent, want))
+}
+
+// nodeKind describes the kind of node for use in error messages.
+func nodeKind(node Node) string {
+	switch node.(type) {
+	case Map:
+		return "a map"
+	case List:
+		return "a list"
+	case Scalar:
+		return "a scalar"
+	}
+	return "an unknown node"
+}
+
+// splitComment separates a trailing comment, which begins with a '#' at the
+// start of the line or after a space, from the content of a line.  A '#'
+// inside a quoted scalar does not start a comment.
+func splitComment(line []byte) (content []byte, comment string) {
+	for i := 0; i < len(line); i++ {
+		atStart := i == 0 || bytes.IndexByte([]byte(" [{,"), line[i-1]) >= 0
+		switch {
+		case line[i] == '#' && (i == 0 || line[i-1] == ' '):
+			return bytes.TrimRight(line[:i], " "), string(line[i:])
+		case atStart && isQuoted(line[i:]):
+			if end := quotedEnd(line[i:]); end > 0 {
+				i += end - 1
+			}
+		}
+	}
+	return line, ""
+}
+
+// joinComments combines the comments found on consecutive lines.
+func joinComments(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+// annotate attaches a trailing comment, which followed the given number of
+// spaces, to the node n describes.
+func annotate(n *note, comment string, space int) *note {
+	if n == nil || comment == "" {
+		return n
+	}
+	if n.Comment == "" {
+		n.layout.commentSpace = space
+	}
+	n.Comment = joinComments(n.Comment, comment)
+	return n
 }
 
 func getType(line []byte) (typ, split int) {
@@ -239,7 +778,7 @@ func getType(line []byte) (typ, split int) {
 		return
 	}
 
-	if line[0] == '-' {
+	if line[0] == '-' && (len(line) == 1 || line[1] == ' ') {
 		typ = typSequence
 		split = 1
 		return
@@ -247,7 +786,27 @@ func getType(line []byte) (typ, split int) {
 
 	typ = typScalar
 
-	if line[0] == ' ' || line[0] == '"' {
+	if isQuoted(line) {
+		// A quoted scalar is a key if it is followed by a colon.
+		end := quotedEnd(line)
+		if end < 0 {
+			return
+		}
+		for i := end; i < len(line); i++ {
+			switch line[i] {
+			case ' ':
+				continue
+			case ':':
+				if i+1 == len(line) || line[i+1] == ' ' {
+					typ, split = typMapping, i
+				}
+			}
+			break
+		}
+		return
+	}
+
+	if line[0] == ' ' || isFlowStart(line) {
 		return
 	}
 
@@ -302,59 +861,223 @@ func getType(line []byte) (typ, split int) {
 
 type lineBuffer struct {
 	*bufio.Reader
+	filename  string
 	readLines int
 	pending   *indentedLine
+	marker    *indentedLine // the document marker which ended the document
+	err       error         // the first error reading from Reader
+
+	// The comments and blank lines skipped by Next which have not been
+	// claimed by Head or Foot.
+	comments []*indentedLine
 }
 
 func (lb *lineBuffer) Next(min int) (next *indentedLine) {
-	if lb.pending == nil {
-		var (
-			read []byte
-			more bool
-			err  error
-		)
-
-		l := new(indentedLine)
-		l.lineno = lb.readLines
-		more = true
-		for more {
-			read, more, err = lb.ReadLine()
-			if err != nil {
-				if err == io.EOF {
-					return nil
-				}
-				panic(err)
-			}
-			l.line = append(l.line, read...)
-		}
-		lb.readLines++
-
-		for _, ch := range l.line {
-			switch ch {
-			case ' ':
-				l.indent += 1
-				continue
-			default:
-			}
-			break
+	for lb.pending == nil {
+		l := lb.read()
+		if l == nil {
+			return nil
 		}
-		l.line = l.line[l.indent:]
 
-		// Ignore blank lines and comments.
-		if len(l.line) == 0 || l.line[0] == '#' {
-			return lb.Next(min)
+		// Set blank lines and comments aside for Head and Foot.
+		if l.blank() {
+			lb.comments = append(lb.comments, l)
+			continue
 		}
 
 		lb.pending = l
 	}
 	next = lb.pending
-	if next.indent < min {
+	if next.indent < min && !next.tabbed {
 		return nil
 	}
 	lb.pending = nil
 	return
 }
 
+// Raw returns the next line without skipping blank lines or comments.
+func (lb *lineBuffer) Raw() *indentedLine {
+	if next := lb.pending; next != nil {
+		lb.pending = nil
+		return next
+	}
+	return lb.read()
+}
+
+func (lb *lineBuffer) Head() string {
+	head := commentText(lb.comments)
+	lb.comments = nil
+	return head
+}
+
+func (lb *lineBuffer) Foot(min int) string {
+	n := 0
+	for i, l := range lb.comments {
+		if len(bytes.TrimSpace(l.line)) == 0 {
+			continue
+		}
+		if l.indent < min {
+			break
+		}
+		n = i + 1
+	}
+	foot := commentText(lb.comments[:n])
+	lb.comments = lb.comments[n:]
+	return foot
+}
+
+// commentText returns the text of comment and blank lines without their
+// indentation, each ending in a newline.
+func commentText(lines []*indentedLine) string {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.Write(bytes.TrimSpace(l.line))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Unread pushes back a line returned by Raw so that it will be considered
+// again by the next call to Next.  A blank line or comment is set aside for
+// Head and Foot, as Next would have done, unless it is empty.
+func (lb *lineBuffer) Unread(line *indentedLine) {
+	if line.blank() {
+		if line.offset == 0 || len(line.line) > 0 {
+			lb.comments = append(lb.comments, line)
+		}
+		return
+	}
+	lb.pending = line
+}
+
+// read returns the next line of the current document.  It returns nil at a
+// document marker, which is kept in lb.marker until nextDocument is called.
+func (lb *lineBuffer) read() *indentedLine {
+	if lb.marker != nil {
+		return nil
+	}
+	l := lb.readLine()
+	if _, _, ok := docMarker(l); ok {
+		lb.marker = l
+		return nil
+	}
+	return l
+}
+
+// nextDocument moves past the end of the current document to the start of
+// the next, skipping blank lines, comments and "..." markers.  It returns the
+// line on which the document starts, which is its "---" marker if it has one,
+// and whether there is another document at all.  Directives, such as
+// "%YAML 1.2", may come before a "---" marker; they are checked and skipped.
+func (lb *lineBuffer) nextDocument() (start *indentedLine, ok bool, err error) {
+	var directive *indentedLine // the last directive, if any
+	for {
+		if lb.marker == nil {
+			l := lb.Next(0)
+			if l != nil && l.indent == 0 && l.line[0] == '%' {
+				if err := checkDirective(l); err != nil {
+					return nil, false, err
+				}
+				directive = l
+				continue
+			}
+			if directive != nil && (l != nil || lb.marker == nil && lb.err == nil) {
+				return nil, false, errorAt(directive, 1, SyntaxError,
+					"directive is not followed by a \"---\" marker")
+			}
+			if l != nil {
+				// A document without a "---" marker.
+				lb.Unread(l)
+				return l, true, nil
+			}
+			if lb.marker == nil {
+				return nil, false, nil
+			}
+		}
+		marker, rest, _ := docMarker(lb.marker)
+		l := lb.marker
+		lb.marker = nil
+		if marker == "---" {
+			// The document may begin on the same line as its marker.
+			lb.Unread(&indentedLine{
+				filename: l.filename,
+				lineno:   l.lineno,
+				offset:   len(l.line) - len(rest),
+				line:     rest,
+				source:   l.source,
+			})
+			return l, true, nil
+		}
+		if directive != nil {
+			return nil, false, errorAt(directive, 1, SyntaxError,
+				"directive is not followed by a \"---\" marker")
+		}
+	}
+}
+
+// checkDirective checks a directive line, which starts with '%'.  A "%YAML"
+// directive must name a version 1 of YAML.  A "%TAG" directive, which would
+// change what tags mean, is not supported, and any other directive is
+// ignored, as the spec requires.
+func checkDirective(l *indentedLine) error {
+	content, _ := splitComment(l.line)
+	fields := strings.Fields(string(content))
+	switch fields[0] {
+	case "%YAML":
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "1.") {
+			return errorAt(l, 1, SyntaxError, "unsupported YAML directive %q", content)
+		}
+	case "%TAG":
+		return errorAt(l, 1, SyntaxError, "TAG directives are not supported")
+	}
+	return nil
+}
+
+// readLine reads the next physical line and measures its indentation.  It
+// returns nil at the end of the input, or if reading fails, in which case
+// the error is recorded in lb.err.
+func (lb *lineBuffer) readLine() *indentedLine {
+	if lb.err != nil {
+		return nil
+	}
+
+	var (
+		read []byte
+		more bool
+		err  error
+	)
+
+	l := new(indentedLine)
+	l.filename = lb.filename
+	l.lineno = lb.readLines
+	more = true
+	for more {
+		read, more, err = lb.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				lb.err = err
+			}
+			return nil
+		}
+		l.line = append(l.line, read...)
+	}
+	lb.readLines++
+	l.source = string(l.line)
+
+	for _, ch := range l.line {
+		switch ch {
+		case ' ':
+			l.indent += 1
+			continue
+		default:
+		}
+		break
+	}
+	l.line = l.line[l.indent:]
+	l.tabbed = len(l.line) > 0 && l.line[0] == '\t'
+	return l
+}
+
 type lineSlice []*indentedLine
 
 func (ls *lineSlice) Next(min int) (next *indentedLine) {
@@ -369,6 +1092,23 @@ func (ls *lineSlice) Next(min int) (next *indentedLine) {
 	return
 }
 
+func (ls *lineSlice) Raw() (next *indentedLine) {
+	if len(*ls) == 0 {
+		return nil
+	}
+	next = (*ls)[0]
+	*ls = (*ls)[1:]
+	return
+}
+
+func (ls *lineSlice) Unread(line *indentedLine) {
+	*ls = append(lineSlice{line}, *ls...)
+}
+
+func (ls *lineSlice) Head() string { return "" }
+
+func (ls *lineSlice) Foot(min int) string { return "" }
+
 func (ls *lineSlice) Push(line *indentedLine) {
 	*ls = append(*ls, line)
 }
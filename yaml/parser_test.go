@@ -16,6 +16,8 @@ package yaml
 
 import (
 	"bytes"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -56,15 +58,18 @@ var parseTests = []struct {
 			"   l: m\n" +
 			"n: o\n" +
 			"",
-		Output: "n: o\n" +
-			"a:\n" +
-			"  l: m\n" +
+		Output: "a:\n" +
 			"  b:\n" +
 			"    c: d\n" +
+			"    # comment\n" +
 			"    e: f\n" +
 			"  g:\n" +
 			"    h: i\n" +
+			"\n" +
 			"    j: k\n" +
+			"  # comment\n" +
+			"  l: m\n" +
+			"n: o\n" +
 			"",
 	},
 	{
@@ -149,34 +154,178 @@ var parseTests = []struct {
 			" - two\n" +
 			" - three\n" +
 			"",
-		Output: "english:\n" +
-			"  - one\n" +
-			"  - two\n" +
-			"  - three\n" +
+		Output: "japanese:\n" +
+			" - ichi\n" +
+			" - ni\n" +
+			" - san\n" +
 			"french:\n" +
-			"  - un\n" +
-			"  - deux\n" +
-			"  - trois\n" +
-			"japanese:\n" +
-			"  - ichi\n" +
-			"  - ni\n" +
-			"  - san\n" +
+			" - un\n" +
+			" - deux\n" +
+			" - trois\n" +
+			"english:\n" +
+			" - one\n" +
+			" - two\n" +
+			" - three\n" +
 			"",
 	},
 	{
 		Input:  `test: "localhost:8080"`,
 		Output: `test: "localhost:8080"` + "\n",
 	},
+	{
+		Input: `"quoted: key": 'it''s'` + "\n" +
+			`'#hash': "tab\there"` + "\n" +
+			`"- ": "-"` + "\n" +
+			"",
+		Output: `"quoted: key": 'it''s'` + "\n" +
+			`'#hash': "tab\there"` + "\n" +
+			`"- ": "-"` + "\n" +
+			"",
+	},
+	{
+		Input: "port: 8080  # http port\n" +
+			"url: http://x.com/#anchor # home\n" +
+			"tag: '#1' # quoted\n" +
+			"list: # items\n" +
+			"  - a#b\n" +
+			"  - [x, # first\n" +
+			"     \"y # z\"] # last\n" +
+			"text: | # literal\n" +
+			"  # kept\n" +
+			"",
+		Output: "port: 8080  # http port\n" +
+			"url: http://x.com/#anchor # home\n" +
+			"tag: '#1' # quoted\n" +
+			"list: # items\n" +
+			"  - a#b\n" +
+			`  - [x, "y # z"] # first # last` + "\n" +
+			"text: | # literal\n" +
+			"  # kept\n" +
+			"",
+	},
+	{
+		Input:  "[a, b, c]\n",
+		Output: "[a, b, c]\n",
+	},
+	{
+		Input:  "{k: v, k2: v2}\n",
+		Output: "{k: v, k2: v2}\n",
+	},
+	{
+		Input:  "key: [ {a: 1}, [x, y] ]\n",
+		Output: "key: [{a: 1}, [x, y]]\n",
+	},
+	{
+		Input: "- [one, two]\n" +
+			"- {name: John, age: 42}\n" +
+			"",
+		Output: "- [one, two]\n" +
+			"- {name: John, age: 42}\n" +
+			"",
+	},
+	{
+		Input:  `hosts: ["a, b", 'c, d', "[e]"]` + "\n",
+		Output: `hosts: ["a, b", 'c, d', "[e]"]` + "\n",
+	},
+	{
+		Input: "servers: [\n" +
+			"  alpha,\n" +
+			"  # comment\n" +
+			"  {name: beta,\n" +
+			"   port: 80},\n" +
+			"]\n" +
+			"other: x\n" +
+			"",
+		Output: "servers: [alpha, {name: beta, port: 80}]\n" +
+			"# comment\n" +
+			"other: x\n" +
+			"",
+	},
+	{
+		Input: "a: b: {c: d}\n" +
+			"   e: [f]\n" +
+			"",
+		Output: "a:\n" +
+			"  b: {c: d}\n" +
+			"  e: [f]\n" +
+			"",
+	},
+	{
+		Input: "empty: {}\n" +
+			"list: [url: http://x.com/, {k}]\n" +
+			"",
+		Output: "empty: {}\n" +
+			"list: [{url: http://x.com/}, {k:}]\n" +
+			"",
+	},
+	{
+		Input: "folded: >\n" +
+			"  one two\n" +
+			"\n" +
+			"    indented\n" +
+			"  three\n" +
+			"strip: |-\n" +
+			"  text\n" +
+			"keep: >+\n" +
+			"  text\n" +
+			"\n" +
+			"clip: |\n" +
+			"  text\n" +
+			"",
+		Output: "folded: >\n" +
+			"  one two\n" +
+			"\n" +
+			"    indented\n" +
+			"  three\n" +
+			"strip: |-\n" +
+			"  text\n" +
+			"keep: >+\n" +
+			"  text\n" +
+			"\n" +
+			"clip: |\n" +
+			"  text\n" +
+			"",
+	},
+	{
+		Input: "wrapped: >\n" +
+			"  one\n" +
+			"  two\n" +
+			"",
+		Output: "wrapped: >\n" +
+			"  one two\n" +
+			"",
+	},
+	{
+		Input: "\"name\": x\n" +
+			"'it''s': y\n" +
+			"flow: {\"a\": 1, 'b c': 2}\n" +
+			"",
+		Output: "\"name\": x\n" +
+			"'it''s': y\n" +
+			"flow: {\"a\": 1, 'b c': 2}\n" +
+			"",
+	},
+	{
+		Input: "literal: |\n" +
+			"folded:  >-\n" +
+			"quoted:  ''\n" +
+			"",
+		Output: "literal: \"\"\n" +
+			"folded:  \"\"\n" +
+			"quoted:  ''\n" +
+			"",
+	},
 }
 
 func TestParse(t *testing.T) {
 	for idx, test := range parseTests {
 		buf := bytes.NewBufferString(test.Input)
-		node, err := Parse(buf)
+		f, err := new(Parser).ParseFile(buf)
 		if err != nil {
 			t.Errorf("parse: %s", err)
+			continue
 		}
-		if got, want := Render(node), test.Output; got != want {
+		if got, want := f.Render(), test.Output; got != want {
 			t.Errorf("---%d---", idx)
 			t.Errorf("got: %q:\n%s", got, got)
 			t.Errorf("want: %q:\n%s", want, want)
@@ -184,11 +333,339 @@ func TestParse(t *testing.T) {
 	}
 }
 
+var parseErrorTests = []struct {
+	Input string
+	Kind  ErrorKind
+	Err   string
+}{
+	{
+		Input: "[a, b\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:6: missing ']' at end of flow collection`,
+	},
+	{
+		Input: "{a: 1] \n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:6: unexpected ']' in flow collection, want ',' or '}'`,
+	},
+	{
+		Input: "[a] b\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:5: unexpected "b" after flow collection`,
+	},
+	{
+		Input: "key: \"a\" b\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:6: invalid quoted scalar "a" b`,
+	},
+	{
+		Input: "key: \"\\q\"\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:6: unknown escape \q in "\q"`,
+	},
+	{
+		Input: "a: |10\n" +
+			"  text\n",
+		Kind: SyntaxError,
+		Err:  `yaml: 1:4: invalid indentation indicator in "|10": want a digit from 1 to 9`,
+	},
+	{
+		Input: "a: >0-\n" +
+			"  text\n",
+		Kind: SyntaxError,
+		Err:  `yaml: 1:4: invalid indentation indicator in ">0-": want a digit from 1 to 9`,
+	},
+	{
+		Input: "a: |--\n",
+		Kind:  SyntaxError,
+		Err:   `yaml: 1:4: invalid block scalar header "|--"`,
+	},
+	{
+		Input: "key: [x,\n" +
+			"  'a]\n",
+		Kind: SyntaxError,
+		Err:  `yaml: 2:3: unterminated quoted scalar`,
+	},
+	{
+		Input: "a: b\n" +
+			"- c\n",
+		Kind: MixedNodeError,
+		Err:  `yaml: 2:1: cannot add list item to a map`,
+	},
+	{
+		Input: "- a\n" +
+			"b: c\n",
+		Kind: MixedNodeError,
+		Err:  `yaml: 2:1: cannot add key "b" to a list`,
+	},
+	{
+		Input: "a:\n" +
+			"  b: 1\n" +
+			"    c: 2\n",
+		Kind: IndentError,
+		Err:  `yaml: 3:5: inconsistent indentation: found 4 spaces, want 2`,
+	},
+	{
+		Input: "a:\n" +
+			"  b: 1\n" +
+			"   c: 2\n",
+		Kind: IndentError,
+		Err:  `yaml: 3:4: inconsistent indentation: found 3 spaces, want 2`,
+	},
+	{
+		Input: "a:\n" +
+			"  - b\n" +
+			"  c\n",
+		Kind: MixedNodeError,
+		Err:  `yaml: 3:3: cannot append scalar to a list`,
+	},
+	{
+		Input: "a: b #c\n" +
+			"  d\n",
+		Kind: SyntaxError,
+		Err:  `yaml: 2:3: scalar continues after a comment`,
+	},
+	{
+		Input: "- b\n" +
+			"  # c\n" +
+			"  d\n",
+		Kind: SyntaxError,
+		Err:  `yaml: 3:3: scalar continues after a comment`,
+	},
+	{
+		Input: "a: b\n" +
+			"[c]\n",
+		Kind: MixedNodeError,
+		Err:  `yaml: 2:1: cannot append flow collection to a map`,
+	},
+	{
+		Input: "a:\n" +
+			"\tb: 1\n",
+		Kind: TabError,
+		Err:  `yaml: 2:1: tab character in indentation`,
+	},
+	{
+		Input: "a:\n" +
+			"  b: 1\n" +
+			"  \t c: 2\n",
+		Kind: TabError,
+		Err:  `yaml: 3:3: tab character in indentation`,
+	},
+	{
+		Input: "a:\n" +
+			"    b: 1\n" +
+			"  c: 2\n",
+		Kind: IndentError,
+		Err:  `yaml: 3:3: inconsistent indentation: found 2 spaces, want 0`,
+	},
+	{
+		Input: "  a: 1\n" +
+			"b: 2\n",
+		Kind: IndentError,
+		Err:  `yaml: 2:1: inconsistent indentation: found 0 spaces, want 2`,
+	},
+	{
+		Input: "list:\n" +
+			"  - one\n" +
+			"   - two\n",
+		Kind: IndentError,
+		Err:  `yaml: 3:4: inconsistent indentation: found 3 spaces, want 2`,
+	},
+	{
+		Input: "list:\n" +
+			"  - one\n" +
+			" - two\n",
+		Kind: IndentError,
+		Err:  `yaml: 3:2: inconsistent indentation: found 1 spaces, want 0`,
+	},
+	{
+		Input: "- name: a\n" +
+			"   age: 1\n",
+		Kind: IndentError,
+		Err:  `yaml: 2:4: inconsistent indentation: found 3 spaces, want 2`,
+	},
+}
+
+func TestParseError(t *testing.T) {
+	for idx, test := range parseErrorTests {
+		_, err := Parse(bytes.NewBufferString(test.Input))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%d. parse(%q) error = %#v, want a *ParseError", idx, test.Input, err)
+			continue
+		}
+		if got, want := perr.Error(), test.Err; got != want {
+			t.Errorf("%d. parse(%q) error:\n got %q\nwant %q", idx, test.Input, got, want)
+		}
+		if got, want := perr.Kind, test.Kind; got != want {
+			t.Errorf("%d. parse(%q) kind = %s, want %s", idx, test.Input, got, want)
+		}
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	input := "list:\n" +
+		"  - one\n" +
+		"   - two\n" +
+		"  \t- three\n" +
+		"  - four\n" +
+		"\t# an indented comment\n" +
+		"map:\n" +
+		"  a: 1\n" +
+		" b: 2\n"
+
+	var warnings []string
+	p := &Parser{
+		Filename: "lenient.yaml",
+		Warn: func(err *ParseError) {
+			warnings = append(warnings, err.Error())
+		},
+	}
+	f, err := p.ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if got, want := f.Render(), "list:\n"+
+		"  - one\n"+
+		"  - two\n"+
+		"  - three\n"+
+		"  - four\n"+
+		"# an indented comment\n"+
+		"map:\n"+
+		"  a: 1\n"+
+		"b: 2\n"; got != want {
+		t.Errorf("lenient parse:\n%s\nwant:\n%s", got, want)
+	}
+
+	want := []string{
+		"yaml: lenient.yaml:3:4: inconsistent indentation: found 3 spaces, want 2",
+		"yaml: lenient.yaml:4:3: tab character in indentation",
+		"yaml: lenient.yaml:9:2: inconsistent indentation: found 1 spaces, want 0",
+	}
+	if got := strings.Join(warnings, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("warnings:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// A line indented with a tab belongs to the node being read, and a key
+	// indented past its siblings joins them.
+	warnings = nil
+	input = "a:\n" +
+		"\tb: 1\n" +
+		"c:\n" +
+		"  d: 1\n" +
+		"   e: 2\n"
+	if f, err = p.ParseFile(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	for spec, value := range map[string]string{"a.b": "1", "c.d": "1", "c.e": "2"} {
+		if got, err := f.Get(spec); err != nil || got != value {
+			t.Errorf("Get(%q) = %q, %v, want %q", spec, got, err, value)
+		}
+	}
+	want = []string{
+		"yaml: lenient.yaml:2:1: tab character in indentation",
+		"yaml: lenient.yaml:5:4: inconsistent indentation: found 3 spaces, want 2",
+	}
+	if got := strings.Join(warnings, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("warnings:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	input := "name: a\n" +
+		"base: &base\n" +
+		"  host: x\n" +
+		"servers:\n" +
+		"  - <<:   *base\n" +
+		"    host: y\n" +
+		"    port: 1\n" +
+		"    port: 2\n" +
+		"  - {host: z, host: w}\n" +
+		"name: b\n"
+
+	// Without Strict, the last value is kept.
+	node, err := Parse(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if got, want := Render(node.(Map)["name"]), "b\n"; got != want {
+		t.Errorf("name = %q, want %q", got, want)
+	}
+
+	_, err = (&Parser{Strict: true}).Parse(bytes.NewBufferString(input))
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("strict parse error = %#v, want ParseErrors", err)
+	}
+	want := []string{
+		"yaml: 8:5: duplicate key servers[0].port",
+		"yaml: 9:15: duplicate key servers[1].host",
+		"yaml: 10:1: duplicate key name",
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+		if e.Kind != DuplicateKeyError {
+			t.Errorf("%s: kind = %s, want %s", e, e.Kind, DuplicateKeyError)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("strict parse errors:\n got %q\nwant %q", got, want)
+	}
+
+	// The same key may be given to different maps.
+	if _, err := (&Parser{Strict: true}).Parse(bytes.NewBufferString("a: {x: 1}\nb: {x: 2}\n")); err != nil {
+		t.Errorf("strict parse: %s", err)
+	}
+}
+
+type errorReader struct{ err error }
+
+func (r errorReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestParseReadError(t *testing.T) {
+	want := errors.New("disk on fire")
+	if _, err := Parse(errorReader{want}); err != want {
+		t.Errorf("parse error = %v, want %v", err, want)
+	}
+}
+
+func TestParseErrorCaret(t *testing.T) {
+	_, err := (&Parser{Filename: "config.yaml"}).Parse(bytes.NewBufferString("a:\n  key: \"a\" b\n"))
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("parse error = %#v, want a *ParseError", err)
+	}
+	want := "config.yaml:2:8: invalid quoted scalar \"a\" b\n" +
+		"      key: \"a\" b\n" +
+		"           ^"
+	if got := perr.Caret(); got != want {
+		t.Errorf("caret:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The line is quoted as it was written, document marker and all.
+	_, err = Parse(bytes.NewBufferString("--- key: \"a\" b\n"))
+	if perr, ok = err.(*ParseError); !ok {
+		t.Fatalf("parse error = %#v, want a *ParseError", err)
+	}
+	want = "1:10: invalid quoted scalar \"a\" b\n" +
+		"    --- key: \"a\" b\n" +
+		"             ^"
+	if got := perr.Caret(); got != want {
+		t.Errorf("caret:\n%s\nwant:\n%s", got, want)
+	}
+}
+
 var getTypeTests = []struct {
 	Value string
 	Type  int
 	Split int
 }{
+	{
+		Value: "-x",
+		Type:  typScalar,
+	},
 	{
 		Value: "a: b",
 		Type:  typMapping,
@@ -231,3 +708,105 @@ func Test_MultiLineString(t *testing.T) {
 		}
 	}
 }
+
+var splitCommentTests = []struct {
+	Line    string
+	Content string
+	Comment string
+}{
+	{"key: value", "key: value", ""},
+	{"key: value # comment", "key: value", "# comment"},
+	{"# comment", "", "# comment"},
+	{"key: a#b", "key: a#b", ""},
+	{`key: "a # b" # c`, `key: "a # b"`, "# c"},
+	{`'#': "#" #`, `'#': "#"`, "#"},
+	{"key: don't # c", "key: don't", "# c"},
+	{`[a, "b # c", 'd'] # e`, `[a, "b # c", 'd']`, "# e"},
+}
+
+func TestSplitComment(t *testing.T) {
+	for idx, test := range splitCommentTests {
+		content, comment := splitComment([]byte(test.Line))
+		if got, want := string(content), test.Content; got != want {
+			t.Errorf("%d. splitComment(%q) content = %q, want %q", idx, test.Line, got, want)
+		}
+		if got, want := comment, test.Comment; got != want {
+			t.Errorf("%d. splitComment(%q) comment = %q, want %q", idx, test.Line, got, want)
+		}
+	}
+}
+
+func TestLineComment(t *testing.T) {
+	input := "port: 8080  # http port\n" +
+		"list: # items\n" +
+		"  - a\n" +
+		"flow: [a,  # one\n" +
+		"       b]  # two\n" +
+		""
+	f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	want := map[string]string{
+		"port": "# http port",
+		"list": "# items",
+		"flow": "# one\n# two",
+	}
+	for key, comment := range want {
+		info, err := f.Info(key)
+		if err != nil {
+			t.Errorf("Info(%q): %s", key, err)
+			continue
+		}
+		if got := info.Comment; got != comment {
+			t.Errorf("%q comment = %q, want %q", key, got, comment)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "# header\n" +
+		"name: gypsy\n" +
+		"list:\n" +
+		"  - one\n" +
+		"  -   two  # comment\n" +
+		"nest: a: \"b\"\n" +
+		"text: |\n" +
+		"  body\n" +
+		"flow: [x,\n" +
+		"   {y: z}]\n" +
+		""
+	f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	tests := []struct {
+		Spec      string
+		Line, Col int
+	}{
+		{"", 2, 1},
+		{"name", 2, 7},
+		{"list", 4, 3},
+		{"list[0]", 4, 5},
+		{"list[1]", 5, 7},
+		{"nest", 6, 7},
+		{"nest.a", 6, 10},
+		{"text", 7, 7},
+		{"flow", 9, 7},
+		{"flow[0]", 9, 8},
+		{"flow[1]", 10, 4},
+		{"flow[1].y", 10, 8},
+	}
+	for _, test := range tests {
+		pos, err := f.Position(test.Spec)
+		if err != nil {
+			t.Errorf("Position(%q): %s", test.Spec, err)
+			continue
+		}
+		if got, want := pos, (Position{Line: test.Line, Column: test.Col}); got != want {
+			t.Errorf("Position(%q) = %s, want %s", test.Spec, got, want)
+		}
+	}
+}
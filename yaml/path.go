@@ -0,0 +1,547 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A PathError describes a path which is not well formed.
+type PathError struct {
+	Spec   string // the path, as given
+	Offset int    // the byte offset in Spec at which the problem was found
+	Msg    string // a description of the problem
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("yaml: bad path %q at offset %d: %s", e.Spec, e.Offset, e.Msg)
+}
+
+// A pathToken is one step of a path.
+type pathToken struct {
+	text string // the token as written, such as ".key" or "[0]"
+	kind tokenKind
+
+	key        string // for keyToken
+	index      int    // for indexToken
+	start, end *int   // for sliceToken, nil if omitted
+	filter     *filter
+
+	// recursive is set for a token written after "..", which applies to
+	// the node and to every node beneath it.
+	recursive bool
+}
+
+type tokenKind int
+
+const (
+	keyToken      tokenKind = iota // .key
+	indexToken                     // [0], [-1]
+	wildcardToken                  // .* or [*]
+	sliceToken                     // [1:3]
+	filterToken                    // [?(@.key == "value")]
+)
+
+// concrete reports whether the token specifies at most one node.
+func (tok pathToken) concrete() bool {
+	return !tok.recursive && (tok.kind == keyToken || tok.kind == indexToken && tok.index >= 0)
+}
+
+// splitPath splits spec into its tokens.  It also returns spec with the
+// implied "." at its start, as used in the errors returned by Child.
+func splitPath(spec string) (string, []pathToken, error) {
+	orig := spec
+	if spec == "" {
+		return "", nil, nil
+	}
+	offset := 0
+	if first := spec[0]; first != '.' && first != '[' {
+		spec = "." + spec
+		offset = -1
+	}
+	fail := func(pos int, format string, args ...interface{}) (string, []pathToken, error) {
+		return "", nil, &PathError{orig, pos + offset, fmt.Sprintf(format, args...)}
+	}
+
+	var toks []pathToken
+	for pos := 0; pos < len(spec); {
+		start := pos
+		recursive := strings.HasPrefix(spec[pos:], "..")
+		if recursive {
+			pos++
+			if strings.HasPrefix(spec[pos:], ".[") {
+				pos++
+			}
+		}
+
+		switch spec[pos] {
+		case '[':
+			if strings.HasPrefix(spec[pos:], "[?(") {
+				end := filterEnd(spec[pos+3:])
+				if end < 0 || !strings.HasPrefix(spec[pos+3+end:], ")]") {
+					return fail(pos, "missing \")]\" at the end of the filter")
+				}
+				f, at, err := parseFilter(spec[pos+3 : pos+3+end])
+				if err != nil {
+					return fail(pos+3+at, "%s", err)
+				}
+				pos += 3 + end + 2
+				toks = append(toks, pathToken{
+					text:      spec[start:pos],
+					kind:      filterToken,
+					filter:    f,
+					recursive: recursive,
+				})
+				continue
+			}
+			var tok pathToken
+			if isQuoted([]byte(spec[pos+1:])) {
+				key, end, err := quotedKey(spec[pos+1:])
+				if err != nil {
+					return fail(pos+1, "%s", err)
+				}
+				end += pos + 1
+				if !strings.HasPrefix(spec[end:], "]") {
+					return fail(end, "missing ']'")
+				}
+				tok, pos = pathToken{kind: keyToken, key: key}, end+1
+			} else {
+				end := strings.IndexByte(spec[pos:], ']')
+				if end < 0 {
+					return fail(pos, "missing ']'")
+				}
+				end += pos
+				var err error
+				if tok, err = bracketToken(spec[pos+1 : end]); err != nil {
+					return fail(pos+1, "%s", err)
+				}
+				pos = end + 1
+			}
+			tok.text, tok.recursive = spec[start:pos], recursive
+			toks = append(toks, tok)
+		case '.':
+			tok := pathToken{kind: keyToken}
+			if isQuoted([]byte(spec[pos+1:])) {
+				key, end, err := quotedKey(spec[pos+1:])
+				if err != nil {
+					return fail(pos+1, "%s", err)
+				}
+				tok.key, pos = key, pos+1+end
+				if pos < len(spec) && spec[pos] != '.' && spec[pos] != '[' {
+					return fail(pos, "unexpected %q", spec[pos])
+				}
+			} else {
+				key, end, err := plainKey(spec[pos+1:])
+				if err != nil {
+					return fail(pos+1+end, "%s", err)
+				}
+				if spec[pos+1:pos+1+end] == "*" {
+					tok.kind = wildcardToken
+				}
+				tok.key, pos = key, pos+1+end
+			}
+			if recursive && pos == start+2 {
+				return fail(pos, "missing key after \"..\"")
+			}
+			tok.text, tok.recursive = spec[start:pos], recursive
+			toks = append(toks, tok)
+		default:
+			return fail(pos, "unexpected %q", spec[pos])
+		}
+	}
+	return spec, toks, nil
+}
+
+// plainKey reads an unquoted key from the start of text, up to the first
+// '.' or '[' which is not escaped by a backslash.  It returns the key and its
+// length in text; on error, the length is the offset of the problem.
+func plainKey(text string) (string, int, error) {
+	var key []byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '.', '[':
+			return string(key), i, nil
+		case '\\':
+			if i++; i == len(text) {
+				return "", i - 1, fmt.Errorf("trailing backslash")
+			}
+			key = append(key, text[i])
+		default:
+			key = append(key, c)
+		}
+	}
+	return string(key), len(text), nil
+}
+
+// quotedKey reads a single- or double-quoted key from the start of text, and
+// returns it with the length of its quoted form.
+func quotedKey(text string) (string, int, error) {
+	end := quotedEnd([]byte(text))
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated quoted key")
+	}
+	key, _, err := unquote(text[:end])
+	if err != nil {
+		return "", 0, err
+	}
+	return key, end, nil
+}
+
+// bracketToken parses the text between the brackets of an index, a slice
+// or a wildcard.
+func bracketToken(inner string) (pathToken, error) {
+	if inner == "*" {
+		return pathToken{kind: wildcardToken}, nil
+	}
+	if colon := strings.IndexByte(inner, ':'); colon >= 0 {
+		tok := pathToken{kind: sliceToken}
+		for _, part := range []struct {
+			text string
+			dst  **int
+		}{{inner[:colon], &tok.start}, {inner[colon+1:], &tok.end}} {
+			if part.text == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part.text)
+			if err != nil {
+				return tok, fmt.Errorf("invalid slice %q", inner)
+			}
+			*part.dst = &n
+		}
+		return tok, nil
+	}
+	idx, err := strconv.Atoi(inner)
+	if err != nil {
+		return pathToken{}, fmt.Errorf("invalid index %q", inner)
+	}
+	return pathToken{kind: indexToken, index: idx}, nil
+}
+
+// BuildPath returns a path to the node given by segments, which may be given
+// to Child, Select or File.Get.  Each int in segments is an index of a List,
+// and anything else is formatted with fmt.Sprint as a key of a Map.  A key
+// which holds '.', '[' or another character with a meaning in a path is
+// quoted, so BuildPath("hosts", "example.com", 0) returns
+// `hosts["example.com"][0]`.
+func BuildPath(segments ...interface{}) string {
+	path := ""
+	for _, seg := range segments {
+		if idx, ok := seg.(int); ok {
+			path = indexPath(path, idx)
+			continue
+		}
+		path = keyPath(path, fmt.Sprint(seg))
+	}
+	return path
+}
+
+// needsPathQuotes reports whether key must be quoted to be read back from a
+// path as the same key.
+func needsPathQuotes(key string) bool {
+	return key == "" || key == "*" || isQuoted([]byte(key)) ||
+		strings.ContainsAny(key, ".[]\\")
+}
+
+// A Path is a compiled path, which finds its nodes without parsing it again.
+// It is safe for concurrent use.
+type Path struct {
+	spec string // the path, as given
+	full string // with the implied "." at its start
+	toks []pathToken
+
+	// concrete is nil if the path specifies at most one node, or the error
+	// returned by the methods which need one.
+	concrete error
+}
+
+// CompilePath parses spec, written as for Select, into a Path.  It returns a
+// *PathError if spec is not well formed.
+func CompilePath(spec string) (*Path, error) {
+	full, toks, err := splitPath(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Path{
+		spec:     spec,
+		full:     full,
+		toks:     toks,
+		concrete: checkConcrete(spec, toks),
+	}, nil
+}
+
+// MustCompilePath is like CompilePath, but panics if spec is not well
+// formed.  This is a convenience function for use in initializers.
+func MustCompilePath(spec string) *Path {
+	p, err := CompilePath(spec)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the path as it was given to CompilePath.
+func (p *Path) String() string {
+	return p.spec
+}
+
+// Child returns the node at the path beneath root, as Child does.
+func (p *Path) Child(root Node) (Node, error) {
+	node, _, err := p.find(root, nil)
+	return node, err
+}
+
+// find returns the node at the path beneath root, which is described by n,
+// along with the note which describes it.
+func (p *Path) find(root Node, n *note) (Node, *note, error) {
+	if p.concrete != nil {
+		return nil, nil, p.concrete
+	}
+
+	node, last := root, ""
+	for _, tok := range p.toks {
+		if node == nil {
+			return nil, nil, &NodeNotFound{
+				Full: p.full,
+				Spec: last,
+			}
+		}
+		if err := checkKind(node, tok, p.full, last); err != nil {
+			return nil, nil, err
+		}
+
+		var ok bool
+		switch s := node.(type) {
+		case List:
+			if ok = tok.index < len(s); ok {
+				node, n = s[tok.index], n.item(s, tok.index)
+			}
+		case Map:
+			n = n.entry(s, tok.key)
+			node, ok = s[tok.key]
+		}
+		last += tok.text
+		if !ok {
+			return nil, nil, &NodeNotFound{
+				Full: p.full,
+				Spec: last,
+			}
+		}
+	}
+	return node, n, nil
+}
+
+// Get returns the text of the Scalar at the path in f, as File.Get does.
+func (p *Path) Get(f *File) (string, error) {
+	node, err := p.Child(f.Root)
+	if err != nil {
+		return "", err
+	}
+	if err = scalarAt(node, p.spec); err != nil {
+		return "", err
+	}
+	return node.(Scalar).String(), nil
+}
+
+// Select returns every node beneath root which matches the path, as Select
+// does.
+func (p *Path) Select(root Node) []Match {
+	return selectTokens(Match{Path: "", Node: root}, p.toks, CoreSchema)
+}
+
+// SelectFile returns every node of f which matches the path, as File.Select
+// does.
+func (p *Path) SelectFile(f *File) []Match {
+	return selectTokens(Match{Node: f.Root, note: f.notes.of(f.Root)}, p.toks, f.schema)
+}
+
+// Set puts node at the path in f, as File.Set does.
+func (p *Path) Set(f *File, node Node) error {
+	return f.editPath(p, true, func(Node, *note) (Node, *note, error) {
+		return node, nil, nil
+	})
+}
+
+// A Match is a node found by Select, with the path at which it was found.
+type Match struct {
+	Path string // the path of the node, as accepted by Child
+	Node Node
+
+	note *note // describes Node, for File.Select
+}
+
+// Select returns every node beneath root which matches spec.  The path is
+// written as for Child, but may also hold these selectors, which can match
+// more than one node:
+//
+//	.*       every value of a Map, or every item of a List
+//	[*]      the same
+//	[-1]     the last item of a List; negative indices count from the end
+//	[1:3]    the items of a List from index 1 up to, but not including, 3;
+//	         either bound may be omitted or negative
+//	..key    the key of the node, or of any node beneath it, at any depth;
+//	         ".." may also come before "*" or a bracketed selector
+//	[?(@.role == "primary")]
+//	         every value of a Map, or item of a List, for which the
+//	         filter holds; see below
+//
+// A filter compares the Scalar found by a path relative to the value, which
+// starts with "@", with another such path or with a literal: a number, a
+// quoted string, or any other word.  The operators are ==, !=, <, <=, > and
+// >=.  If both sides are numbers, as resolved by Resolve, they are compared
+// as numbers; otherwise their text is compared as strings.  File.Select
+// compares a quoted scalar of the file, or a quoted literal, as a string.  A
+// filter with no operator, such as [?(@.backup)], holds if its path finds a
+// node.  A path which finds no node, or a Map or a List, makes any
+// comparison false.
+//
+// The nodes are returned in the order in which they are found: in the order
+// of the List or of the Map's keys (see Keys, and File.Select for the order
+// of a file), and for "..", a node before the nodes beneath it.  Each Match
+// holds the concrete path of its node, such as "servers[2].host", which may
+// be given to Child or to File.Set.  A selector which does not fit its node,
+// such as a key of a List, matches nothing; Select only returns an error if
+// spec is not well formed.
+func Select(root Node, spec string) ([]Match, error) {
+	p, err := CompilePath(spec)
+	if err != nil {
+		return nil, err
+	}
+	return p.Select(root), nil
+}
+
+// Select is like the Select function, for the nodes of the file.  The values
+// of each Map are matched in the order in which their keys were written, and
+// a filter takes into account which scalars of the file were quoted or
+// tagged, and the schema by which the file was read.
+func (f *File) Select(spec string) ([]Match, error) {
+	p, err := CompilePath(spec)
+	if err != nil {
+		return nil, err
+	}
+	return p.SelectFile(f), nil
+}
+
+// selectTokens returns the nodes beneath root which match toks.  The tags
+// of plain scalars compared by filters are resolved according to schema.
+func selectTokens(root Match, toks []pathToken, schema Schema) []Match {
+	matches := []Match{root}
+	for _, tok := range toks {
+		var next []Match
+		for _, m := range matches {
+			if !tok.recursive {
+				next = tok.match(next, m, schema)
+				continue
+			}
+			walkMatches(m, func(m Match) {
+				next = tok.match(next, m, schema)
+			})
+		}
+		matches = next
+	}
+	return matches
+}
+
+// walkMatches calls fn with m and with every node beneath it, in order.
+func walkMatches(m Match, fn func(Match)) {
+	fn(m)
+	switch n := m.Node.(type) {
+	case Map:
+		for _, key := range keysOf(n, m.note) {
+			walkMatches(m.key(n, key), fn)
+		}
+	case List:
+		for i := range n {
+			walkMatches(m.index(n, i), fn)
+		}
+	}
+}
+
+// key returns the Match for the value of key in m, the Map of the match.
+func (m Match) key(n Map, key string) Match {
+	return Match{Path: keyPath(m.Path, key), Node: n[key], note: m.note.entry(n, key)}
+}
+
+// index returns the Match for the item at idx in list, the List of the match.
+func (m Match) index(list List, idx int) Match {
+	return Match{Path: indexPath(m.Path, idx), Node: list[idx], note: m.note.item(list, idx)}
+}
+
+// match appends the children of m which match tok to matches.
+func (tok pathToken) match(matches []Match, m Match, schema Schema) []Match {
+	switch n := m.Node.(type) {
+	case Map:
+		switch tok.kind {
+		case keyToken:
+			if _, ok := n[tok.key]; ok {
+				matches = append(matches, m.key(n, tok.key))
+			}
+		case wildcardToken, filterToken:
+			for _, key := range keysOf(n, m.note) {
+				child := m.key(n, key)
+				if tok.kind == filterToken && !tok.filter.holds(child, schema) {
+					continue
+				}
+				matches = append(matches, child)
+			}
+		}
+	case List:
+		start, end := 0, 0
+		switch tok.kind {
+		case indexToken:
+			start = tok.index
+			if start < 0 {
+				start += len(n)
+			}
+			end = start + 1
+		case wildcardToken:
+			start, end = 0, len(n)
+		case filterToken:
+			for i := range n {
+				if child := m.index(n, i); tok.filter.holds(child, schema) {
+					matches = append(matches, child)
+				}
+			}
+		case sliceToken:
+			start, end = sliceBound(tok.start, 0, len(n)), sliceBound(tok.end, len(n), len(n))
+		}
+		for i := start; i < end; i++ {
+			if i >= 0 && i < len(n) {
+				matches = append(matches, m.index(n, i))
+			}
+		}
+	}
+	return matches
+}
+
+// sliceBound returns the index given by a bound of a slice of a List of
+// length n, or def if it was omitted.
+func sliceBound(bound *int, def, n int) int {
+	if bound == nil {
+		return def
+	}
+	i := *bound
+	if i < 0 {
+		i += n
+	}
+	switch {
+	case i < 0:
+		return 0
+	case i > n:
+		return n
+	}
+	return i
+}
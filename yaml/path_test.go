@@ -0,0 +1,303 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const selectInput = `name: web
+servers:
+  - host: a
+    port: 80
+  - host: b
+    port: 81
+    backup:
+      host: d
+  - host: c
+tags: [x, y, z]
+`
+
+var selectTests = []struct {
+	Spec string
+	Want []string // path=value of each match
+}{
+	{"", []string{"=(map)"}},
+	{"name", []string{"name=web"}},
+	{".servers[1].host", []string{"servers[1].host=b"}},
+	{"missing", nil},
+	{"servers[*].host", []string{"servers[0].host=a", "servers[1].host=b", "servers[2].host=c"}},
+	{"servers.*.port", []string{"servers[0].port=80", "servers[1].port=81"}},
+	{"servers[0].*", []string{"servers[0].host=a", "servers[0].port=80"}},
+	{"tags[-1]", []string{"tags[2]=z"}},
+	{"tags[-4]", nil},
+	{"tags[1:3]", []string{"tags[1]=y", "tags[2]=z"}},
+	{"tags[:-1]", []string{"tags[0]=x", "tags[1]=y"}},
+	{"tags[-2:]", []string{"tags[1]=y", "tags[2]=z"}},
+	{"tags[5:9]", nil},
+	{"..host", []string{"servers[0].host=a", "servers[1].host=b", "servers[1].backup.host=d", "servers[2].host=c"}},
+	{"servers[1]..host", []string{"servers[1].host=b", "servers[1].backup.host=d"}},
+	{"..[0]", []string{"servers[0]=(map)", "tags[0]=x"}},
+	{"servers..*", []string{
+		"servers[0]=(map)", "servers[1]=(map)", "servers[2]=(map)",
+		"servers[0].host=a", "servers[0].port=80",
+		"servers[1].host=b", "servers[1].port=81", "servers[1].backup=(map)",
+		"servers[1].backup.host=d",
+		"servers[2].host=c",
+	}},
+	{"name.*", nil},
+	{"tags.x", nil},
+}
+
+// describeMatches writes each match as path=value for comparison.
+func describeMatches(matches []Match) []string {
+	var got []string
+	for _, m := range matches {
+		value := "(map)"
+		if s, ok := m.Node.(Scalar); ok {
+			value = string(s)
+		}
+		got = append(got, m.Path+"="+value)
+	}
+	return got
+}
+
+func TestSelect(t *testing.T) {
+	f := Config(selectInput)
+	root := f.Root
+	for _, test := range selectTests {
+		matches, err := f.Select(test.Spec)
+		if err != nil {
+			t.Errorf("Select(%q): %s", test.Spec, err)
+			continue
+		}
+		if got := describeMatches(matches); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Select(%q):\n got %q\nwant %q", test.Spec, got, test.Want)
+		}
+
+		// The path of each match finds the same node.
+		for _, m := range matches {
+			if node, err := Child(root, m.Path); err != nil || !sameNode(node, m.Node) {
+				t.Errorf("Select(%q): Child(%q) = %v, %v, want the match", test.Spec, m.Path, node, err)
+			}
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	tests := []struct {
+		Spec string
+		Err  string
+	}{
+		{"servers[1", `yaml: bad path "servers[1" at offset 7: missing ']'`},
+		{"servers[a]", `yaml: bad path "servers[a]" at offset 8: invalid index "a"`},
+		{"tags[1:b]", `yaml: bad path "tags[1:b]" at offset 5: invalid slice "1:b"`},
+		{"servers..", `yaml: bad path "servers.." at offset 9: missing key after ".."`},
+		{"tags[0]x", `yaml: bad path "tags[0]x" at offset 7: unexpected 'x'`},
+	}
+	for _, test := range tests {
+		_, err := Select(nil, test.Spec)
+		if got := fmt.Sprint(err); got != test.Err {
+			t.Errorf("Select(%q) error = %q, want %q", test.Spec, got, test.Err)
+		}
+		if _, ok := err.(*PathError); !ok {
+			t.Errorf("Select(%q) error = %#v, want a *PathError", test.Spec, err)
+		}
+	}
+}
+
+func TestSetNeedsConcretePath(t *testing.T) {
+	f := Config(selectInput)
+	for _, spec := range []string{"servers[*].host", "tags[-1]", "..host", "tags[0:1]", `servers[?(@.port)].host`} {
+		err := f.Set(spec, Scalar("x"))
+		if _, ok := err.(*PathError); !ok || !strings.Contains(err.Error(), "can only be used by Select") {
+			t.Errorf("Set(%q) error = %v, want a PathError", spec, err)
+		}
+	}
+}
+
+const quotedKeyInput = `hosts:
+  example.com:
+    port: 443
+  a[b]: brackets
+  'it''s': quote
+  back\slash: backslash
+  "*": star
+  "": empty
+  "my host": spaced
+`
+
+func TestQuotedKeys(t *testing.T) {
+	tests := []struct {
+		Spec string
+		Want string
+	}{
+		{`hosts["example.com"].port`, "443"},
+		{`hosts['example.com'].port`, "443"},
+		{`hosts."example.com".port`, "443"},
+		{`hosts.'example.com'["port"]`, "443"},
+		{`hosts.example\.com.port`, "443"},
+		{`["hosts"]["a[b]"]`, "brackets"},
+		{`hosts.a\[b\]`, "brackets"},
+		{`hosts["it's"]`, "quote"},
+		{`hosts['it''s']`, "quote"},
+		{`hosts.back\\slash`, "backslash"},
+		{`hosts["back\\slash"]`, "backslash"},
+		{`hosts["*"]`, "star"},
+		{`hosts.\*`, "star"},
+		{`hosts[""]`, "empty"},
+		{`hosts.my host`, "spaced"},
+		{`hosts[?(@.port == 443)].port`, "443"},
+		{`hosts[?(@["port"] == 443)].port`, "443"},
+	}
+	root := Config(quotedKeyInput).Root
+	for _, test := range tests {
+		matches, err := Select(root, test.Spec)
+		if err != nil || len(matches) != 1 {
+			t.Errorf("Select(%q) = %v, %v, want one match", test.Spec, matches, err)
+			continue
+		}
+		if got := fmt.Sprint(matches[0].Node); got != test.Want {
+			t.Errorf("Select(%q) = %q, want %q", test.Spec, got, test.Want)
+		}
+		if node, err := Child(root, matches[0].Path); err != nil || !sameNode(node, matches[0].Node) {
+			t.Errorf("Select(%q): Child(%q) = %v, %v, want the match", test.Spec, matches[0].Path, node, err)
+		}
+	}
+
+	for _, test := range []struct {
+		Spec string
+		Err  string
+	}{
+		{`hosts["example.com`, `yaml: bad path "hosts[\"example.com" at offset 6: unterminated quoted key`},
+		{`hosts["example.com"`, `yaml: bad path "hosts[\"example.com\"" at offset 19: missing ']'`},
+		{`hosts.'a'b`, `yaml: bad path "hosts.'a'b" at offset 9: unexpected 'b'`},
+		{`hosts.a\`, `yaml: bad path "hosts.a\\" at offset 7: trailing backslash`},
+	} {
+		_, err := Child(root, test.Spec)
+		if got := fmt.Sprint(err); got != test.Err {
+			t.Errorf("Child(%q) error = %q, want %q", test.Spec, got, test.Err)
+		}
+	}
+}
+
+func TestBuildPath(t *testing.T) {
+	tests := []struct {
+		Segments []interface{}
+		Want     string
+	}{
+		{nil, ""},
+		{[]interface{}{"hosts", "www", "port"}, "hosts.www.port"},
+		{[]interface{}{"hosts", "example.com", 0}, `hosts["example.com"][0]`},
+		{[]interface{}{0, "a[b]"}, `[0]["a[b]"]`},
+		{[]interface{}{"*", `back\slash`, ""}, `["*"]["back\\slash"][""]`},
+		{[]interface{}{"'quoted'", "my host", "7", 7}, `["'quoted'"].my host.7[7]`},
+	}
+	for _, test := range tests {
+		if got := BuildPath(test.Segments...); got != test.Want {
+			t.Errorf("BuildPath(%q) = %q, want %q", test.Segments, got, test.Want)
+		}
+	}
+
+	// Each key reads back as itself.
+	f := new(File)
+	for _, key := range []string{"example.com", "a[b]", "*", `x\y`, `"q"`, "'", "..", "", "a b"} {
+		spec := BuildPath("keys", key)
+		if err := f.Set(spec, Scalar(key)); err != nil {
+			t.Errorf("Set(%q): %s", spec, err)
+			continue
+		}
+		if got, err := f.Get(spec); err != nil || got != key {
+			t.Errorf("Get(%q) = %q, %v, want %q", spec, got, err, key)
+		}
+		if keys, _ := f.Root.(Map)["keys"].(Map); keys[key] == nil {
+			t.Errorf("Set(%q) did not set the key %q", spec, key)
+		}
+	}
+}
+
+func TestCompilePath(t *testing.T) {
+	if _, err := CompilePath("servers[x].host"); err == nil {
+		t.Errorf("CompilePath(servers[x].host) succeeded, want a PathError")
+	} else if _, ok := err.(*PathError); !ok {
+		t.Errorf("CompilePath(servers[x].host) error = %#v, want a *PathError", err)
+	}
+
+	host := MustCompilePath(`servers[1]["host"]`)
+	if got, want := host.String(), `servers[1]["host"]`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, input := range []string{selectInput, editInput} {
+		f := Config(input)
+		if got, err := host.Get(f); err != nil || got != "b" {
+			t.Errorf("Get = %q, %v, want b", got, err)
+		}
+		if err := host.Set(f, Scalar("e")); err != nil {
+			t.Errorf("Set: %s", err)
+		}
+		if got, err := f.Get("servers[1].host"); err != nil || got != "e" {
+			t.Errorf("after Set, Get = %q, %v, want e", got, err)
+		}
+	}
+
+	f := Config(selectInput)
+	if _, err := MustCompilePath("servers[3].host").Get(f); fmt.Sprint(err) != `yaml: .servers[3].host: ".servers[3]" not found` {
+		t.Errorf("Get(servers[3].host) error = %v, want not found", err)
+	}
+	if _, err := MustCompilePath("servers").Get(f); fmt.Sprint(err) != `yaml: servers: type mismatch: "servers" is yaml.List, want yaml.Scalar (at "$")` {
+		t.Errorf("Get(servers) error = %v, want a type mismatch", err)
+	}
+
+	hosts := MustCompilePath("servers[*].host")
+	if got, want := describeMatches(hosts.Select(f.Root)), []string{"servers[0].host=a", "servers[1].host=b", "servers[2].host=c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Select = %q, want %q", got, want)
+	}
+	if got, want := describeMatches(hosts.SelectFile(f)), []string{"servers[0].host=a", "servers[1].host=b", "servers[2].host=c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectFile = %q, want %q", got, want)
+	}
+
+	// SelectFile, like File.Select, knows which scalars were quoted and the
+	// schema by which the file was read; Select does not.
+	p := &Parser{Schema: YAML11Schema}
+	limits, err := p.ParseFile(strings.NewReader("- {name: a, max: 1_000}\n- {name: b, max: \"1e3\"}\n"))
+	if err != nil {
+		t.Fatalf("ParseFile: %s", err)
+	}
+	large := MustCompilePath("[?(@.max == 1000)].name")
+	want, _ := limits.Select(large.String())
+	if got := describeMatches(large.SelectFile(limits)); !reflect.DeepEqual(got, describeMatches(want)) || !reflect.DeepEqual(got, []string{"[0].name=a"}) {
+		t.Errorf("SelectFile(%s) = %q, want [0].name=a as from File.Select", large, got)
+	}
+	if got, want := describeMatches(large.Select(limits.Root)), []string{"[1].name=b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Select(%s) = %q, want %q", large, got, want)
+	}
+
+	if _, err := hosts.Get(f); err == nil || !strings.Contains(err.Error(), "can only be used by Select") {
+		t.Errorf("Get(servers[*].host) error = %v, want a PathError", err)
+	}
+	if err := hosts.Set(f, Scalar("x")); err == nil || !strings.Contains(err.Error(), "can only be used by Select") {
+		t.Errorf("Set(servers[*].host) error = %v, want a PathError", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustCompilePath(a[) did not panic")
+		}
+	}()
+	MustCompilePath("a[")
+}
@@ -0,0 +1,344 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schema is a set of rules for resolving the tag of a plain scalar, which
+// says what sort of value it holds.
+type Schema int
+
+const (
+	// CoreSchema is the core schema of YAML 1.2.  Plain scalars are nulls
+	// ("", "~", "null"), booleans ("true", "false"), integers (decimal
+	// with an optional sign, "0o" octal or "0x" hexadecimal), floats
+	// ("1.5", "6.02e23", ".inf", ".nan") or timestamps ("2013-05-14",
+	// "2013-05-14T09:30:00Z").
+	CoreSchema Schema = iota
+
+	// YAML11Schema resolves plain scalars as YAML 1.1 did, for files
+	// written for older parsers.  In addition to the core schema, "yes",
+	// "no", "on", "off", "y" and "n" are booleans, integers with a leading
+	// "0" are octal, integers with a leading "0b" are binary, any integer
+	// may have a sign, and the digits of an integer or float may be
+	// separated by underscores, as in "1_000".
+	YAML11Schema
+)
+
+func (schema Schema) String() string {
+	switch schema {
+	case CoreSchema:
+		return "core"
+	case YAML11Schema:
+		return "yaml1.1"
+	}
+	return fmt.Sprintf("Schema(%d)", int(schema))
+}
+
+// resolveTag returns the core schema tag of a plain scalar.
+func resolveTag(s string) string {
+	return CoreSchema.tag(s)
+}
+
+// tag returns the tag of the plain scalar s.
+func (schema Schema) tag(s string) string {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return NullTag
+	}
+	if _, ok := schema.parseBool(s); ok {
+		return BoolTag
+	}
+	if _, _, _, ok := schema.intDigits(s); ok {
+		return IntTag
+	}
+	if schema.isFloat(s) || isSpecialFloat(s) {
+		return FloatTag
+	}
+	if _, ok := parseTimestamp(s); ok {
+		return TimestampTag
+	}
+	return StrTag
+}
+
+// Resolve returns the kind of value node holds, as a tag of the core schema,
+// and the value itself:
+//
+//	!!null       nil
+//	!!bool       a bool
+//	!!int        an int64, or a uint64 if it is too large for an int64
+//	!!float      a float64
+//	!!timestamp  a time.Time
+//	!!str        a string
+//
+// The kind is resolved from the text of the node according to the core
+// schema; File.Resolve also takes into account the tag a node of a file was
+// given and whether it was quoted.  For a Map or List, Resolve returns
+// "!!map" or "!!seq" and a nil value.  An error is returned if the text of
+// the node cannot be held by the kind, such as an integer which does not fit
+// in 64 bits.
+func Resolve(node Node) (kind string, value interface{}, err error) {
+	return resolve(node, nil, CoreSchema)
+}
+
+// resolve is Resolve for node, which is described by n.  The kind is the tag
+// n records if it is one of the core schema, "!!str" if the node was quoted,
+// and otherwise is resolved from its text according to schema.  A Scalar with
+// a tag outside the core schema, such as "!port 80", holds the value its
+// text resolves to.
+func resolve(node Node, n *note, schema Schema) (kind string, value interface{}, err error) {
+	scalar, ok := node.(Scalar)
+	if !ok {
+		return tagOf(node, n, schema), nil, nil
+	}
+	s := string(scalar)
+
+	kind = schema.tag(s)
+	if n != nil {
+		switch n.Tag {
+		case StrTag, NullTag, BoolTag, IntTag, FloatTag, TimestampTag:
+			kind = n.Tag
+		case "":
+			if n.Style != PlainStyle {
+				kind = StrTag
+			}
+		}
+	}
+
+	switch kind {
+	case NullTag:
+		if s == "" || schema.tag(s) == NullTag {
+			return kind, nil, nil
+		}
+	case BoolTag:
+		if b, ok := schema.parseBool(s); ok {
+			return kind, b, nil
+		}
+	case IntTag:
+		if _, _, _, ok := schema.intDigits(s); ok {
+			value, err := schema.parseInt(s)
+			if err != nil {
+				return kind, nil, fmt.Errorf("%q does not fit in 64 bits", s)
+			}
+			return kind, value, nil
+		}
+	case FloatTag:
+		if f, err := schema.parseFloat(s); err == nil {
+			return kind, f, nil
+		}
+	case TimestampTag:
+		if t, ok := parseTimestamp(s); ok {
+			return kind, t, nil
+		}
+	default:
+		return StrTag, s, nil
+	}
+	return kind, nil, fmt.Errorf("%q is not a valid %s", s, kind)
+}
+
+// parseBool parses a boolean.
+func (schema Schema) parseBool(s string) (value, ok bool) {
+	switch s {
+	case "true", "True", "TRUE":
+		return true, true
+	case "false", "False", "FALSE":
+		return false, true
+	}
+	if schema == YAML11Schema {
+		switch s {
+		case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON":
+			return true, true
+		case "n", "N", "no", "No", "NO", "off", "Off", "OFF":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// intDigits splits an integer into its sign and its digits, without any
+// underscores, in the given base.  It reports whether s is an integer.
+func (schema Schema) intDigits(s string) (neg bool, digits string, base int, ok bool) {
+	signed := strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+")
+	if signed {
+		neg, s = s[0] == '-', s[1:]
+	}
+
+	digits, base = s, 10
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		digits, base = s[2:], 16
+	case strings.HasPrefix(s, "0o") && schema == CoreSchema:
+		digits, base = s[2:], 8
+	case strings.HasPrefix(s, "0b") && schema == YAML11Schema:
+		digits, base = s[2:], 2
+	case strings.HasPrefix(s, "0") && len(s) > 1 && schema == YAML11Schema:
+		digits, base = s[1:], 8
+	}
+	if signed && base != 10 && schema == CoreSchema {
+		return false, "", 0, false
+	}
+	if !schema.isNumber(digits, baseDigits[base]) {
+		return false, "", 0, false
+	}
+	return neg, strings.Replace(digits, "_", "", -1), base, true
+}
+
+// parseInt parses an integer, returning an int64 if it fits in one and a
+// uint64 if not.
+func (schema Schema) parseInt(s string) (interface{}, error) {
+	neg, digits, base, ok := schema.intDigits(s)
+	if !ok {
+		return nil, strconv.ErrSyntax
+	}
+	u, err := strconv.ParseUint(digits, base, 64)
+	switch {
+	case err != nil:
+		return nil, err
+	case neg && u > 1<<63:
+		return nil, strconv.ErrRange
+	case neg:
+		return -int64(u), nil
+	case u > math.MaxInt64:
+		return u, nil
+	}
+	return int64(u), nil
+}
+
+// parseFloat parses a float, or an integer as a float.
+func (schema Schema) parseFloat(s string) (float64, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, "+")) {
+	case ".inf":
+		return math.Inf(1), nil
+	case "-.inf":
+		return math.Inf(-1), nil
+	case ".nan":
+		return math.NaN(), nil
+	}
+	if _, _, _, ok := schema.intDigits(s); ok {
+		i, err := schema.parseInt(s)
+		switch i := i.(type) {
+		case int64:
+			return float64(i), nil
+		case uint64:
+			return float64(i), nil
+		}
+		return 0, err
+	}
+	if schema.isFloat(s) {
+		return strconv.ParseFloat(strings.Replace(s, "_", "", -1), 64)
+	}
+	return 0, strconv.ErrSyntax
+}
+
+// isNumber reports whether s is made up of the given digits, which under
+// YAML11Schema may be separated by underscores.
+func (schema Schema) isNumber(s, digits string) bool {
+	if s == "" || s[0] == '_' || s[len(s)-1] == '_' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && schema == YAML11Schema {
+			continue
+		}
+		if strings.IndexByte(digits, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+const decimalDigits = "0123456789"
+
+// baseDigits holds the digits of an integer in each base.
+var baseDigits = map[int]string{
+	2:  "01",
+	8:  "01234567",
+	10: decimalDigits,
+	16: "0123456789abcdefABCDEF",
+}
+
+// isSpecialFloat reports whether s is infinity or not-a-number.
+func isSpecialFloat(s string) bool {
+	switch s {
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF",
+		"-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		return true
+	}
+	return false
+}
+
+// isFloat reports whether s is a float (other than infinity and
+// not-a-number), such as "1.5", "-.5", "2." or "6.02e23".
+func (schema Schema) isFloat(s string) bool {
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i+1:]
+		if strings.HasPrefix(exponent, "-") || strings.HasPrefix(exponent, "+") {
+			exponent = exponent[1:]
+		}
+		if !schema.isNumber(exponent, decimalDigits) {
+			return false
+		}
+	}
+	whole, frac := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, frac = mantissa[:i], mantissa[i+1:]
+	}
+	switch {
+	case whole == "" && frac == "":
+		return false
+	case whole != "" && !schema.isNumber(whole, decimalDigits):
+		return false
+	case frac != "" && !schema.isNumber(frac, decimalDigits):
+		return false
+	}
+	return true
+}
+
+// timestampLayouts are the forms of an ISO 8601 timestamp: a date, perhaps
+// followed by a time separated from it by a 'T' or a space, perhaps with a
+// fraction of a second and a time zone.  A timestamp without a time zone is
+// in UTC.
+var timestampLayouts = []string{
+	"2006-1-2",
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2T15:4:5.999999999",
+	"2006-1-2 15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999",
+}
+
+// parseTimestamp parses an ISO 8601 timestamp.
+func parseTimestamp(s string) (time.Time, bool) {
+	// Only try the layouts on text which starts with a year.
+	if len(s) < len("2006-1-2") || !CoreSchema.isNumber(s[:4], decimalDigits) || s[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,181 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var resolveTests = []struct {
+	Schema Schema
+	Text   string
+	Kind   string
+	Value  interface{}
+}{
+	{CoreSchema, "", NullTag, nil},
+	{CoreSchema, "~", NullTag, nil},
+	{CoreSchema, "NULL", NullTag, nil},
+	{CoreSchema, "true", BoolTag, true},
+	{CoreSchema, "False", BoolTag, false},
+	{CoreSchema, "yes", StrTag, "yes"},
+	{CoreSchema, "off", StrTag, "off"},
+	{CoreSchema, "42", IntTag, int64(42)},
+	{CoreSchema, "-17", IntTag, int64(-17)},
+	{CoreSchema, "0755", IntTag, int64(755)},
+	{CoreSchema, "0o755", IntTag, int64(0755)},
+	{CoreSchema, "0x1F", IntTag, int64(31)},
+	{CoreSchema, "1_000", StrTag, "1_000"},
+	{CoreSchema, "0xFFFF_FFFF", StrTag, "0xFFFF_FFFF"},
+	{CoreSchema, "18446744073709551615", IntTag, uint64(math.MaxUint64)},
+	{CoreSchema, "-9223372036854775808", IntTag, int64(math.MinInt64)},
+	{CoreSchema, "-0x10", StrTag, "-0x10"},
+	{CoreSchema, "0b101", StrTag, "0b101"},
+	{CoreSchema, "_1", StrTag, "_1"},
+	{CoreSchema, "1_", StrTag, "1_"},
+	{CoreSchema, "1.5", FloatTag, 1.5},
+	{CoreSchema, "-.5e10", FloatTag, -.5e10},
+	{CoreSchema, "1_000.25", StrTag, "1_000.25"},
+	{CoreSchema, ".inf", FloatTag, math.Inf(1)},
+	{CoreSchema, "-.INF", FloatTag, math.Inf(-1)},
+	{CoreSchema, "2013-05-14", TimestampTag, time.Date(2013, 5, 14, 0, 0, 0, 0, time.UTC)},
+	{CoreSchema, "2013-5-4T09:30:00Z", TimestampTag, time.Date(2013, 5, 4, 9, 30, 0, 0, time.UTC)},
+	{CoreSchema, "2013-05-14 09:30:00.5", TimestampTag, time.Date(2013, 5, 14, 9, 30, 0, 5e8, time.UTC)},
+	{CoreSchema, "2013-05-14T09:30:00+02:00", TimestampTag, time.Date(2013, 5, 14, 7, 30, 0, 0, time.UTC)},
+	{CoreSchema, "2013-05-14x", StrTag, "2013-05-14x"},
+	{CoreSchema, "hello", StrTag, "hello"},
+	{YAML11Schema, "yes", BoolTag, true},
+	{YAML11Schema, "N", BoolTag, false},
+	{YAML11Schema, "On", BoolTag, true},
+	{YAML11Schema, "0755", IntTag, int64(0755)},
+	{YAML11Schema, "0b1010", IntTag, int64(10)},
+	{YAML11Schema, "-0x10", IntTag, int64(-16)},
+	{YAML11Schema, "0o7", StrTag, "0o7"},
+	{YAML11Schema, "1_000", IntTag, int64(1000)},
+	{YAML11Schema, "0xFFFF_FFFF", IntTag, int64(0xFFFFFFFF)},
+	{YAML11Schema, "1_000.25", FloatTag, 1000.25},
+	{YAML11Schema, "_1", StrTag, "_1"},
+	{YAML11Schema, "~", NullTag, nil},
+}
+
+func TestResolve(t *testing.T) {
+	for _, test := range resolveTests {
+		node := Scalar(test.Text)
+		kind, value, err := resolve(node, nil, test.Schema)
+		if err != nil {
+			t.Errorf("Resolve(%q) in %s: %s", test.Text, test.Schema, err)
+			continue
+		}
+		if kind != test.Kind {
+			t.Errorf("Resolve(%q) in %s kind = %s, want %s", test.Text, test.Schema, kind, test.Kind)
+		}
+		if want, ok := test.Value.(time.Time); ok {
+			if got, ok := value.(time.Time); !ok || !got.Equal(want) {
+				t.Errorf("Resolve(%q) in %s = %#v, want %s", test.Text, test.Schema, value, want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(value, test.Value) {
+			t.Errorf("Resolve(%q) in %s = %#v, want %#v", test.Text, test.Schema, value, test.Value)
+		}
+		if got := tagOf(node, nil, test.Schema); got != test.Kind {
+			t.Errorf("TagOf(%q) in %s = %s, want %s", test.Text, test.Schema, got, test.Kind)
+		}
+	}
+}
+
+func TestResolveNodes(t *testing.T) {
+	tests := []struct {
+		Node  Node
+		Info  NodeInfo // how the node was written
+		Kind  string
+		Value interface{}
+		Err   string
+	}{
+		{Scalar("0x10"), NodeInfo{}, IntTag, int64(16), ""},
+		{Scalar("true"), NodeInfo{Style: DoubleQuotedStyle}, StrTag, "true", ""},
+		{Scalar("12"), NodeInfo{Tag: StrTag}, StrTag, "12", ""},
+		{Scalar("12"), NodeInfo{Tag: FloatTag}, FloatTag, 12.0, ""},
+		{Scalar("80"), NodeInfo{Tag: "!port"}, IntTag, int64(80), ""},
+		{Scalar("x"), NodeInfo{Tag: IntTag}, IntTag, nil, `"x" is not a valid !!int`},
+		{Scalar("99999999999999999999"), NodeInfo{}, IntTag, nil, `"99999999999999999999" does not fit in 64 bits`},
+		{Map{"a": Scalar("1")}, NodeInfo{}, MapTag, nil, ""},
+		{List{}, NodeInfo{}, SeqTag, nil, ""},
+	}
+	for _, test := range tests {
+		kind, value, err := resolve(test.Node, &note{NodeInfo: test.Info, node: test.Node}, CoreSchema)
+		if got := fmt.Sprint(err); test.Err != "" && got != test.Err || test.Err == "" && err != nil {
+			t.Errorf("Resolve(%#v) error = %v, want %q", test.Node, err, test.Err)
+		}
+		if kind != test.Kind || !reflect.DeepEqual(value, test.Value) {
+			t.Errorf("Resolve(%#v) = %s, %#v, want %s, %#v", test.Node, kind, value, test.Kind, test.Value)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	input := "enabled: yes\n" +
+		"mode:    0644\n" +
+		"name:    !!str no\n" +
+		"list:    [on, off]\n"
+
+	core, err := Parse(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	var got map[string]interface{}
+	if err := Unmarshal(core, &got); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	want := map[string]interface{}{
+		"enabled": "yes",
+		"mode":    int64(644),
+		"name":    "no",
+		"list":    []interface{}{"on", "off"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("core schema:\n got %#v\nwant %#v", got, want)
+	}
+
+	old, err := (&Parser{Schema: YAML11Schema}).ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	got = nil
+	if err := old.Decode(&got); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+	want = map[string]interface{}{
+		"enabled": true,
+		"mode":    int64(0644),
+		"name":    "no",
+		"list":    []interface{}{true, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("YAML 1.1 schema:\n got %#v\nwant %#v", got, want)
+	}
+
+	// Tags are checked against the schema.
+	if _, err := (&Parser{Schema: YAML11Schema}).Parse(bytes.NewBufferString("a: !!bool yes\n")); err != nil {
+		t.Errorf("parse !!bool yes in YAML 1.1: %s", err)
+	}
+	if _, err := Parse(bytes.NewBufferString("a: !!bool yes\n")); err == nil {
+		t.Errorf("parse !!bool yes in the core schema succeeded")
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A blockHeader holds the indicators which follow the '|' or '>' introducing
+// a LONG-SCALAR.
+type blockHeader struct {
+	folded bool // '>' instead of '|'
+	chomp  byte // '-' (strip), '+' (keep) or 0 (clip)
+	indent int  // explicit indentation, or 0 to detect it
+}
+
+// parseBlockHeader parses the header of a block scalar such as "|", ">-" or
+// "|2+".  The chomping and indentation indicators may be given in either
+// order.
+func parseBlockHeader(text []byte) (h blockHeader, err error) {
+	text = bytes.TrimRight(text, " ")
+	if !isBlockHeader(text) {
+		return h, fmt.Errorf("invalid block scalar header %q", text)
+	}
+	h.folded = text[0] == '>'
+	for i := 1; i < len(text); i++ {
+		switch ch := text[i]; {
+		case (ch == '-' || ch == '+') && h.chomp == 0:
+			h.chomp = ch
+		case ch >= '0' && ch <= '9' && h.indent == 0:
+			if ch == '0' || i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9' {
+				return h, fmt.Errorf("invalid indentation indicator in %q: want a digit from 1 to 9", text)
+			}
+			h.indent = int(ch - '0')
+		default:
+			return h, fmt.Errorf("invalid block scalar header %q", text)
+		}
+	}
+	return h, nil
+}
+
+// isBlockHeader reports whether text is the header of a literal or folded
+// block scalar: a '|' or '>', followed only by indicators, which may not be
+// valid ones.
+func isBlockHeader(text []byte) bool {
+	text = bytes.TrimRight(text, " ")
+	if len(text) == 0 || (text[0] != '|' && text[0] != '>') {
+		return false
+	}
+	return len(bytes.Trim(text[1:], "+-0123456789")) == 0
+}
+
+// readBlockScalar reads the body of the block scalar introduced by header on
+// a line indented by parent spaces.  The body continues until the first
+// non-blank line which is indented less than its first line (or less than
+// the explicit indentation indicator).  Blank lines inside the body are
+// preserved.
+func readBlockScalar(r lineReader, header string, parent int) (string, error) {
+	h, err := parseBlockHeader([]byte(header))
+	if err != nil {
+		return "", err
+	}
+
+	indent := 0
+	if h.indent > 0 {
+		indent = parent + h.indent
+	}
+
+	var lines []string
+	var blanks []*indentedLine // the blank lines since the last line of text
+	var next *indentedLine     // the line after the body
+	for {
+		l := r.Raw()
+		if l == nil {
+			break
+		}
+		if len(l.line) == 0 {
+			blanks = append(blanks, l)
+			// Whitespace beyond the block's indentation is content.
+			extra := ""
+			if indent > 0 && l.indent > indent {
+				extra = strings.Repeat(" ", l.indent-indent)
+			}
+			lines = append(lines, extra)
+			continue
+		}
+		if indent == 0 {
+			if l.indent <= parent {
+				next = l
+				break
+			}
+			indent = l.indent
+		}
+		if l.indent < indent {
+			next = l
+			break
+		}
+		lines = append(lines, strings.Repeat(" ", l.indent-indent)+string(l.line))
+		blanks = nil
+	}
+
+	// Unless they are kept, the trailing blank lines are not part of the
+	// scalar, so return them to be read as the blank lines before the next
+	// node.
+	if h.chomp != '+' {
+		for _, l := range blanks {
+			r.Unread(l)
+		}
+	}
+	if next != nil {
+		r.Unread(next)
+	}
+
+	// Separate the trailing blank lines, which are subject to chomping.
+	end := len(lines)
+	for end > 0 && strings.TrimLeft(lines[end-1], " ") == "" {
+		end--
+	}
+	body, trailing := lines[:end], len(lines)-end
+
+	var text string
+	if h.folded {
+		text = foldLines(body)
+	} else {
+		text = strings.Join(body, "\n")
+	}
+
+	switch {
+	case len(body) == 0 && h.chomp != '+':
+		return "", nil
+	case h.chomp == '-':
+		return text, nil
+	case h.chomp == '+':
+		if len(body) == 0 {
+			return strings.Repeat("\n", trailing), nil
+		}
+		return text + strings.Repeat("\n", trailing+1), nil
+	}
+	return text + "\n", nil
+}
+
+// foldLines joins the lines of a folded block scalar.  Line breaks between
+// two lines of text become spaces and each blank line becomes a newline,
+// but the line breaks around more-indented lines are kept.
+func foldLines(lines []string) string {
+	var (
+		buf      bytes.Buffer
+		blanks   int
+		started  bool
+		prevMore bool
+	)
+	for _, line := range lines {
+		if strings.TrimLeft(line, " ") == "" {
+			blanks++
+			continue
+		}
+		more := line[0] == ' '
+		switch {
+		case !started:
+			buf.WriteString(strings.Repeat("\n", blanks))
+		case !prevMore && !more && blanks == 0:
+			buf.WriteByte(' ')
+		case !prevMore && !more:
+			buf.WriteString(strings.Repeat("\n", blanks))
+		default:
+			buf.WriteString(strings.Repeat("\n", blanks+1))
+		}
+		buf.WriteString(line)
+		started, blanks, prevMore = true, 0, more
+	}
+	return buf.String()
+}
+
+// blockText returns the header and body lines with which text is rendered as
+// a block scalar: a folded one if folded is set and text can be written as
+// one, and otherwise a literal one.  The chomping indicator of the header
+// keeps the trailing newlines of text.
+func blockText(text string, folded bool) (header string, lines []string) {
+	body := strings.TrimRight(text, "\n")
+	if body == "" && text != "" {
+		// Only newlines, which are all kept as trailing blank lines.
+		return "|+", make([]string, len(text))
+	}
+
+	header, lines = "|", strings.Split(body, "\n")
+	if folded {
+		if f, ok := foldText(body); ok {
+			header, lines = ">", f
+		}
+	}
+	if strings.HasPrefix(strings.TrimLeft(body, "\n"), " ") {
+		header += "2"
+	}
+	switch trailing := len(text) - len(body); {
+	case trailing == 0:
+		header += "-"
+	case trailing > 1:
+		header += "+"
+		for i := 1; i < trailing; i++ {
+			lines = append(lines, "")
+		}
+	}
+	return header, lines
+}
+
+// foldText returns the lines of a folded block scalar which foldLines joins
+// into text, with each line of text on a line of its own, or false if there
+// are none.
+func foldText(text string) (lines []string, ok bool) {
+	newlines, started, prevMore := 0, false, false
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			newlines++
+		}
+		if line == "" {
+			continue
+		}
+		// A line break between two lines of text is folded into a
+		// space, so each newline of text needs a blank line, except
+		// around more-indented lines, whose line breaks are kept.
+		more := line[0] == ' '
+		blanks := newlines
+		if started && (prevMore || more) {
+			blanks--
+		}
+		for ; blanks > 0; blanks-- {
+			lines = append(lines, "")
+		}
+		lines = append(lines, line)
+		newlines, started, prevMore = 0, true, more
+	}
+	if !started || foldLines(lines) != text {
+		return nil, false
+	}
+	return lines, true
+}
+
+// isQuoted reports whether text begins with a quoted scalar.
+func isQuoted(text []byte) bool {
+	return len(text) > 0 && (text[0] == '"' || text[0] == '\'')
+}
+
+// quotedEnd returns the index just past the closing quote of the quoted
+// scalar at the start of text, or -1 if it is not terminated.
+func quotedEnd(text []byte) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if q == '"' {
+				i++
+			}
+		case q:
+			if q == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// unquote decodes a single- or double-quoted scalar, including its quotes,
+// and reports which style it was written in.
+func unquote(text string) (string, Style, error) {
+	if len(text) < 2 || text[len(text)-1] != text[0] {
+		return "", PlainStyle, fmt.Errorf("unterminated quoted scalar %s", text)
+	}
+	body := text[1 : len(text)-1]
+
+	if text[0] == '\'' {
+		return strings.Replace(body, "''", "'", -1), SingleQuotedStyle, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		if ch != '\\' {
+			buf.WriteByte(ch)
+			continue
+		}
+		if i++; i == len(body) {
+			return "", PlainStyle, fmt.Errorf("unterminated escape in %s", text)
+		}
+		if r, ok := simpleEscapes[body[i]]; ok {
+			buf.WriteRune(r)
+			continue
+		}
+		digits := 0
+		switch body[i] {
+		case 'x':
+			digits = 2
+		case 'u':
+			digits = 4
+		case 'U':
+			digits = 8
+		default:
+			return "", PlainStyle, fmt.Errorf("unknown escape \\%c in %s", body[i], text)
+		}
+		if i+digits >= len(body) {
+			return "", PlainStyle, fmt.Errorf("short escape \\%s in %s", body[i:], text)
+		}
+		code, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", PlainStyle, fmt.Errorf("invalid escape \\%s in %s", body[i:i+1+digits], text)
+		}
+		buf.WriteRune(rune(code))
+		i += digits
+	}
+	return buf.String(), DoubleQuotedStyle, nil
+}
+
+var simpleEscapes = map[byte]rune{
+	'0':  0,
+	'a':  '\a',
+	'b':  '\b',
+	't':  '\t',
+	'\t': '\t',
+	'n':  '\n',
+	'v':  '\v',
+	'f':  '\f',
+	'r':  '\r',
+	'e':  0x1b,
+	' ':  ' ',
+	'"':  '"',
+	'/':  '/',
+	'\\': '\\',
+	'N':  0x85,
+	'_':  0xa0,
+	'L':  0x2028,
+	'P':  0x2029,
+}
+
+// quote returns text as a scalar written in the given style.  Single quotes
+// cannot hold a line break, so such text is always double-quoted.
+func quote(text string, style Style) string {
+	if style == SingleQuotedStyle && !strings.ContainsAny(text, "\n\r") {
+		return "'" + strings.Replace(text, "'", "''", -1) + "'"
+	}
+	return strconv.Quote(text)
+}
+
+// needsQuotes reports whether text would not be read back as the same
+// string if it were written as a plain scalar.
+func needsQuotes(text string) bool {
+	return text != "" && (strings.TrimSpace(text) != text ||
+		strings.IndexByte("[]{}\"'|>&*!#%@`,?", text[0]) >= 0 ||
+		strings.HasPrefix(text, "- ") || text == "-" ||
+		strings.Contains(text, ": ") || strings.Contains(text, " #") ||
+		strings.HasSuffix(text, ":") || strings.ContainsAny(text, "\r\t"))
+}
+
+// quoteKey returns key in a form which will be read back as the same map
+// key, quoting it only if necessary.
+func quoteKey(key string) string {
+	if key == "" || strings.TrimSpace(key) != key ||
+		strings.IndexByte("-[]{}\"'|>&*!#%@`,?", key[0]) >= 0 ||
+		strings.Contains(key, ": ") || strings.Contains(key, " #") ||
+		strings.HasSuffix(key, ":") || strings.ContainsAny(key, "\n\r\t") {
+		return strconv.Quote(key)
+	}
+	return key
+}
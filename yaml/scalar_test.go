@@ -0,0 +1,213 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var blockScalarTests = []struct {
+	Input string
+	Want  string
+}{
+	{
+		Input: "v: |\n  a\n  b\n",
+		Want:  "a\nb\n",
+	},
+	{
+		Input: "v: |\n  def f():\n      return 1\n\n  # not a comment\n",
+		Want:  "def f():\n    return 1\n\n# not a comment\n",
+	},
+	{
+		Input: "v: |-\n  a\n  b\n\n\nw: x\n",
+		Want:  "a\nb",
+	},
+	{
+		Input: "v: |+\n  a\n  b\n\n\nw: x\n",
+		Want:  "a\nb\n\n\n",
+	},
+	{
+		Input: "v: |\n  a\n\n\n",
+		Want:  "a\n",
+	},
+	{
+		Input: "v: |2\n    indented\n  plain\n",
+		Want:  "  indented\nplain\n",
+	},
+	{
+		Input: "v: |-2\n    x\n",
+		Want:  "  x",
+	},
+	{
+		Input: "v: >\n  lorem ipsum\n  dolor\n\n  sit amet\n",
+		Want:  "lorem ipsum dolor\nsit amet\n",
+	},
+	{
+		Input: "v: >\n  lorem ipsum\n\n    dolor\n\n  sit amet\n",
+		Want:  "lorem ipsum\n\n  dolor\n\nsit amet\n",
+	},
+	{
+		Input: "v: >-\n  folded\n  line\n    * bullet\n    * list\n  last\n",
+		Want:  "folded line\n  * bullet\n  * list\nlast",
+	},
+	{
+		Input: "- |\n  one\n- >\n  two\n  lines\n",
+		Want:  "one\n",
+	},
+	{
+		Input: "v: |\nw: x\n",
+		Want:  "",
+	},
+	{
+		Input: "v:\n  lorem ipsum\n   dolor sit amet\n",
+		Want:  "lorem ipsum dolor sit amet",
+	},
+	{
+		Input: "v: >=1.2\n",
+		Want:  ">=1.2",
+	},
+}
+
+func TestBlockScalar(t *testing.T) {
+	for idx, test := range blockScalarTests {
+		node, err := Parse(bytes.NewBufferString(test.Input))
+		if err != nil {
+			t.Errorf("%d. parse(%q): %s", idx, test.Input, err)
+			continue
+		}
+		var got Node
+		switch node := node.(type) {
+		case Map:
+			got = node["v"]
+		case List:
+			got = node[0]
+		}
+		if got, want := got, Scalar(test.Want); got != want {
+			t.Errorf("%d. parse(%q) = %q, want %q", idx, test.Input, got, want)
+		}
+	}
+}
+
+var foldLinesTests = []struct {
+	Lines []string
+	Want  string
+}{
+	{[]string{"a", "b"}, "a b"},
+	{[]string{"a", "", "b"}, "a\nb"},
+	{[]string{"", "a"}, "\na"},
+	{[]string{"a", "  b", "c"}, "a\n  b\nc"},
+	{[]string{"a", "  b", "", "  c"}, "a\n  b\n\n  c"},
+}
+
+func TestFoldLines(t *testing.T) {
+	for idx, test := range foldLinesTests {
+		if got, want := foldLines(test.Lines), test.Want; got != want {
+			t.Errorf("%d. foldLines(%q) = %q, want %q", idx, test.Lines, got, want)
+		}
+	}
+}
+
+func TestBlockScalarRoundTrip(t *testing.T) {
+	values := []string{
+		"a\nb\n",
+		"a\nb",
+		"a\n\nb\n\n",
+		"  indented\nplain\n",
+	}
+	for _, value := range values {
+		text := Render(Map{"v": Scalar(value)})
+		node, err := Parse(bytes.NewBufferString(text))
+		if err != nil {
+			t.Errorf("parse(%q): %s", text, err)
+			continue
+		}
+		if got, want := node.(Map)["v"], Scalar(value); got != want {
+			t.Errorf("round trip of %q through %q = %q", want, text, got)
+		}
+	}
+}
+
+var unquoteTests = []struct {
+	Input string
+	Want  string
+	Style Style
+	Err   string
+}{
+	{Input: `"plain"`, Want: "plain", Style: DoubleQuotedStyle},
+	{Input: `'plain'`, Want: "plain", Style: SingleQuotedStyle},
+	{Input: `"a\tb\nc"`, Want: "a\tb\nc", Style: DoubleQuotedStyle},
+	{Input: `"say \"hi\" \\o/"`, Want: `say "hi" \o/`, Style: DoubleQuotedStyle},
+	{Input: `"\u00e9t\u00E9 \x41 \U0001F600"`, Want: "\u00e9t\u00e9 A \U0001F600", Style: DoubleQuotedStyle},
+	{Input: `'it''s a \n'`, Want: `it's a \n`, Style: SingleQuotedStyle},
+	{Input: `""`, Want: "", Style: DoubleQuotedStyle},
+	{Input: `"bad \q"`, Err: `unknown escape \q in "bad \q"`},
+	{Input: `"short \u12"`, Err: `short escape \u12 in "short \u12"`},
+	{Input: `"nothex \uzzzz"`, Err: `invalid escape \uzzzz in "nothex \uzzzz"`},
+	{Input: `"open`, Err: `unterminated quoted scalar "open`},
+}
+
+func TestUnquote(t *testing.T) {
+	for idx, test := range unquoteTests {
+		got, style, err := unquote(test.Input)
+		if err != nil {
+			if got, want := err.Error(), test.Err; got != want {
+				t.Errorf("%d. unquote(%s) error %q, want %q", idx, test.Input, got, want)
+			}
+			continue
+		}
+		if test.Err != "" {
+			t.Errorf("%d. unquote(%s) succeeded, want error %q", idx, test.Input, test.Err)
+		}
+		if want := test.Want; got != want {
+			t.Errorf("%d. unquote(%s) = %q, want %q", idx, test.Input, got, want)
+		}
+		if want := test.Style; style != want {
+			t.Errorf("%d. unquote(%s) style = %d, want %d", idx, test.Input, style, want)
+		}
+	}
+}
+
+func TestQuotedScalar(t *testing.T) {
+	input := `"url: http": "a # b"` + "\n" +
+		`single: 'x: y'` + "\n" +
+		`flow: ["a", 'b']` + "\n"
+	f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	m := f.Root.(Map)
+
+	want := map[string]string{"url: http": "a # b", "single": "x: y"}
+	for key, value := range want {
+		if got := m[key]; got != Scalar(value) {
+			t.Errorf("%q = %q, want %q", key, got, value)
+		}
+		if info, err := f.Info(BuildPath(key)); err != nil || !info.Quoted() {
+			t.Errorf("%q = %#v, %v, want a quoted scalar", key, info, err)
+		}
+	}
+
+	list := m["flow"].(List)
+	if got, want := list, (List{Scalar("a"), Scalar("b")}); !reflect.DeepEqual(got, want) {
+		t.Errorf("flow = %q, want %q", got, want)
+	}
+	for spec, style := range map[string]Style{"flow[0]": DoubleQuotedStyle, "flow[1]": SingleQuotedStyle} {
+		if info, _ := f.Info(spec); info.Style != style {
+			t.Errorf("%s style = %d, want %d", spec, info.Style, style)
+		}
+	}
+}
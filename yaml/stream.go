@@ -0,0 +1,165 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+// A Decoder reads a stream of YAML documents, separated by "---" markers,
+// one at a time.
+type Decoder struct {
+	lines  *lineBuffer
+	parser *parser
+	start  *indentedLine // the line on which the last document started
+	err    error
+}
+
+// NewDecoder returns a Decoder which reads documents from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return new(Parser).NewDecoder(r)
+}
+
+// NewDecoder returns a Decoder which reads documents from r with the options
+// of p.
+func (p *Parser) NewDecoder(r io.Reader) *Decoder {
+	lb := &lineBuffer{
+		Reader:   bufio.NewReader(r),
+		filename: p.Filename,
+	}
+	tags := make(map[string]TagHandler, len(p.Tags))
+	for tag, handler := range p.Tags {
+		tags[normalizeTag(tag)] = handler
+	}
+	return &Decoder{
+		lines:  lb,
+		parser: &parser{lines: lb, warn: p.Warn, aliasLimit: p.AliasLimit, strict: p.Strict, schema: p.Schema, tags: tags},
+	}
+}
+
+// Next returns the root node of the next document in the stream, which is
+// nil if the document is empty.  At the end of the stream it returns io.EOF.
+func (d *Decoder) Next() (Node, error) {
+	f, err := d.NextFile()
+	if err != nil {
+		return nil, err
+	}
+	return f.Root, nil
+}
+
+// NextFile is like Next, but it returns the document as a File, which records
+// the position of each node and how it was written.
+func (d *Decoder) NextFile() (*File, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	start, ok, err := d.lines.nextDocument()
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	if !ok {
+		d.err = d.lines.err
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		return nil, d.err
+	}
+	d.start = start
+	d.parser.resetAnchors()
+
+	// The root node's tag is written after the document marker, as in
+	// "--- !tag".
+	var tag string
+	var tagLine *indentedLine
+	if l := d.lines.Next(0); l != nil && start != nil && l.lineno == start.lineno && l.line[0] == '!' {
+		var rest []byte
+		tag, rest = tagName(l.line)
+		tagLine = l
+		d.lines.Unread(&indentedLine{
+			filename: l.filename,
+			lineno:   l.lineno,
+			offset:   l.offset + len(l.line) - len(rest),
+			line:     rest,
+			source:   l.source,
+		})
+	} else if l != nil {
+		d.lines.Unread(l)
+	}
+
+	node, err := d.parser.parseNode(0, nil)
+	if err == nil && tag != "" {
+		node, err = d.parser.applyTag(tagLine, 1, tag, node)
+	}
+	if err == nil {
+		err = d.lines.err
+	}
+	if err == nil {
+		err = d.parser.duplicateErrors(node)
+	}
+	d.lines.comments = nil
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	return &File{Root: node.value(), notes: node, schema: d.parser.schema}, nil
+}
+
+// ReadDocuments reads every document in the given YAML file, returning a File
+// for each of them.
+func ReadDocuments(filename string) ([]*File, error) {
+	fin, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	var files []*File
+	d := (&Parser{Filename: filename}).NewDecoder(fin)
+	for {
+		f, err := d.NextFile()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+}
+
+// docMarker reports whether l is a document marker: a "---" which starts a
+// document, perhaps followed by its first node, or a "..." which ends one.
+func docMarker(l *indentedLine) (marker string, rest []byte, ok bool) {
+	if l == nil || l.indent != 0 || len(l.line) < 3 {
+		return "", nil, false
+	}
+	marker = string(l.line[:3])
+	if marker != "---" && marker != "..." {
+		return "", nil, false
+	}
+	if len(l.line) > 3 && l.line[3] != ' ' && l.line[3] != '\t' {
+		return "", nil, false
+	}
+	rest = bytes.TrimLeft(l.line[3:], " \t")
+	if marker == "..." && len(rest) > 0 && rest[0] != '#' {
+		return "", nil, false
+	}
+	return marker, rest, true
+}
@@ -0,0 +1,239 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var decoderTests = []struct {
+	Input string
+	Docs  []string // rendered documents, with "" for an empty one
+}{
+	{
+		Input: "",
+		Docs:  nil,
+	},
+	{
+		Input: "# nothing but a comment\n",
+		Docs:  nil,
+	},
+	{
+		Input: "a: 1\n",
+		Docs:  []string{"a: 1\n"},
+	},
+	{
+		Input: "---\n" +
+			"a: 1\n" +
+			"---\n" +
+			"- b\n",
+		Docs: []string{"a: 1\n", "- b\n"},
+	},
+	{
+		Input: "a: 1\n" +
+			"--- # second\n" +
+			"b: 2\n" +
+			"...\n" +
+			"---\n" +
+			"c: 3\n" +
+			"...\n",
+		Docs: []string{"a: 1\n", "# second\nb: 2\n", "c: 3\n"},
+	},
+	{
+		Input: "a: 1\n" +
+			"...\n" +
+			"b: 2\n",
+		Docs: []string{"a: 1\n", "b: 2\n"},
+	},
+	{
+		Input: "---\n" +
+			"---\n",
+		Docs: []string{"", ""},
+	},
+	{
+		Input: "--- text\n" +
+			"--- [x, y]\n" +
+			"--- |\n" +
+			"  block\n" +
+			"---\n",
+		Docs: []string{"text\n", "[x, y]\n", "|\n  block\n", ""},
+	},
+	{
+		Input: "text: |\n" +
+			"  line\n" +
+			"---\n" +
+			"list: [a,\n" +
+			"---\n",
+		Docs: []string{
+			"text: |\n  line\n",
+			"error: yaml: 4:10: missing ']' at end of flow collection",
+		},
+	},
+	{
+		Input: "----\n" +
+			"-x\n" +
+			"--- a\n" +
+			" ...\n",
+		Docs: []string{"---- -x\n", "a ...\n"},
+	},
+	{
+		Input: "%YAML 1.2 # version\n" +
+			"%FUTURE directive\n" +
+			"---\n" +
+			"a: 1\n" +
+			"...\n" +
+			"%YAML 1.1\n" +
+			"--- b\n",
+		Docs: []string{"a: 1\n", "b\n"},
+	},
+	{
+		Input: "%YAML 1.2\na: 1\n",
+		Docs:  []string{`error: yaml: 1:1: directive is not followed by a "---" marker`},
+	},
+	{
+		Input: "a: 1\n...\n%YAML 1.2\n",
+		Docs:  []string{"a: 1\n", `error: yaml: 3:1: directive is not followed by a "---" marker`},
+	},
+	{
+		Input: "%YAML 2.0\n---\na: 1\n",
+		Docs:  []string{`error: yaml: 1:1: unsupported YAML directive "%YAML 2.0"`},
+	},
+	{
+		Input: "%TAG ! tag:example.com,2000:\n---\na: 1\n",
+		Docs:  []string{"error: yaml: 1:1: TAG directives are not supported"},
+	},
+}
+
+func TestDecoder(t *testing.T) {
+	for idx, test := range decoderTests {
+		d := NewDecoder(bytes.NewBufferString(test.Input))
+		var docs []string
+		for {
+			f, err := d.NextFile()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				docs = append(docs, "error: "+err.Error())
+				break
+			}
+			if f.Root == nil {
+				docs = append(docs, "")
+				continue
+			}
+			docs = append(docs, f.Render())
+		}
+
+		if got, want := len(docs), len(test.Docs); got != want {
+			t.Errorf("%d. decode(%q) = %d documents %q, want %d", idx, test.Input, got, docs, want)
+			continue
+		}
+		for i := range docs {
+			if got, want := docs[i], test.Docs[i]; got != want {
+				t.Errorf("%d. decode(%q) document %d = %q, want %q", idx, test.Input, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	d := NewDecoder(bytes.NewBufferString("a: 1\n---\nb: [\n---\nc: 3\n"))
+	if _, err := d.Next(); err != nil {
+		t.Fatalf("first document: %s", err)
+	}
+	_, err := d.Next()
+	if got, want := err.Error(), `yaml: 3:5: missing ']' at end of flow collection`; got != want {
+		t.Errorf("second document error = %q, want %q", got, want)
+	}
+	if _, again := d.Next(); again != err {
+		t.Errorf("after an error, Next = %v, want %v", again, err)
+	}
+}
+
+func TestDocumentMarkerPosition(t *testing.T) {
+	f, err := new(Parser).ParseFile(bytes.NewBufferString("--- [x, y]\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	pos, err := f.Position("[1]")
+	if want := (Position{Line: 1, Column: 9}); err != nil || pos != want {
+		t.Errorf("position = %s, %v; want %s", pos, err, want)
+	}
+}
+
+var parseDocumentTests = []struct {
+	Input string
+	Err   string
+}{
+	{Input: "---\na: 1\n"},
+	{Input: "a: 1\n...\n"},
+	{Input: "a: 1\n---\n---\n"},
+	{Input: "a: 1\n---\nb: 2\n", Err: "yaml: 2:1: more than one document in stream"},
+	{Input: "a: 1\n...\nb: 2\n", Err: "yaml: 3:1: more than one document in stream"},
+	{Input: "%YAML 1.2\n---\na: 1\n"},
+	{Input: "%YAML 1.2\na: 1\n", Err: `yaml: 1:1: directive is not followed by a "---" marker`},
+}
+
+func TestParseDocuments(t *testing.T) {
+	for idx, test := range parseDocumentTests {
+		_, err := Parse(bytes.NewBufferString(test.Input))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if want := test.Err; got != want {
+			t.Errorf("%d. parse(%q) error = %q, want %q", idx, test.Input, got, want)
+		}
+	}
+}
+
+func TestReadDocuments(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "gypsy")
+	if err != nil {
+		t.Fatalf("tempfile: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString("name: web\n" +
+		"replicas: 3\n" +
+		"---\n" +
+		"name: db\n" +
+		"replicas: 1\n")
+	tmp.Close()
+
+	files, err := ReadDocuments(tmp.Name())
+	if err != nil {
+		t.Fatalf("readdocuments: %s", err)
+	}
+	if got, want := len(files), 2; got != want {
+		t.Fatalf("read %d documents, want %d", got, want)
+	}
+	for i, want := range []string{"web", "db"} {
+		if got, err := files[i].Get("name"); err != nil || got != want {
+			t.Errorf("document %d: name = %q, %v; want %q", i, got, err, want)
+		}
+	}
+
+	pos, err := files[1].Position("replicas")
+	if err != nil {
+		t.Fatalf("position: %s", err)
+	}
+	if got, want := pos.String(), tmp.Name()+":5:11"; got != want {
+		t.Errorf("position = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The tags of the YAML core schema, in their shorthand form.
+const (
+	StrTag   = "!!str"
+	IntTag   = "!!int"
+	FloatTag = "!!float"
+	BoolTag  = "!!bool"
+	NullTag  = "!!null"
+	MapTag   = "!!map"
+	SeqTag   = "!!seq"
+
+	TimestampTag = "!!timestamp"
+)
+
+// coreTagPrefix is the prefix for which "!!" is shorthand.
+const coreTagPrefix = "tag:yaml.org,2002:"
+
+// A TagHandler is called by the parser for each node which has the tag it
+// was given for in Parser.Tags, along with the details of how the node was
+// written.  The node has already been checked against the core schema if the
+// tag is one of its tags.  The handler may return the node, perhaps
+// modified, or a replacement for it.  An error from the handler ends the
+// parse.
+type TagHandler func(node Node, info *NodeInfo) (Node, error)
+
+// TagOf returns the tag of node, resolved according to the core schema.
+// Maps are "!!map" and lists are "!!seq", while scalars are "!!null",
+// "!!bool", "!!int", "!!float" or "!!timestamp" if they look like one, and
+// "!!str" if not.  TagOf returns "" for nil.  The tags given explicitly in a
+// file, and its quoted scalars, are taken into account by File.Tag.
+func TagOf(node Node) string {
+	return tagOf(node, nil, CoreSchema)
+}
+
+// tagOf returns the tag of node, which is described by n.  This is the tag
+// it was given explicitly, if any; otherwise it is resolved from the node.
+// Quoted and block scalars are "!!str", and the tags of plain scalars are
+// resolved according to schema.
+func tagOf(node Node, n *note, schema Schema) string {
+	if n != nil && n.Tag != "" {
+		return n.Tag
+	}
+	switch node := node.(type) {
+	case Map:
+		return MapTag
+	case List:
+		return SeqTag
+	case Scalar:
+		if n != nil && n.Style != PlainStyle {
+			return StrTag
+		}
+		return schema.tag(string(node))
+	}
+	return ""
+}
+
+// isString reports whether the node n describes was marked as a string, by
+// quoting it or by tagging it "!!str", so that it should not be converted to
+// another type.
+func isString(n *note) bool {
+	if n == nil {
+		return false
+	}
+	if n.Tag != "" {
+		return n.Tag == StrTag
+	}
+	return n.Quoted()
+}
+
+// tagName splits the tag at the start of text, which begins with '!', from
+// the rest of the text.  A verbatim tag such as "!<tag:example.com,2013:x>"
+// ends at its '>'; other tags end at a space or a flow indicator.
+func tagName(text []byte) (tag string, rest []byte) {
+	end := bytes.IndexAny(text, " \t,[]{}")
+	if bytes.HasPrefix(text, []byte("!<")) {
+		end = bytes.IndexByte(text, '>') + 1
+	}
+	if end <= 0 {
+		end = len(text)
+	}
+	return normalizeTag(string(text[:end])), bytes.TrimLeft(text[end:], " ")
+}
+
+// normalizeTag writes tags of the core schema in their shorthand form.
+func normalizeTag(tag string) string {
+	if strings.HasPrefix(tag, "!<"+coreTagPrefix) && strings.HasSuffix(tag, ">") {
+		return "!!" + tag[len("!<"+coreTagPrefix):len(tag)-1]
+	}
+	return tag
+}
+
+// checkTag returns an error if node cannot have the given core schema tag,
+// resolving plain scalars according to schema.
+func checkTag(tag string, node Node, schema Schema) error {
+	var want string
+	switch tag {
+	case MapTag:
+		if _, ok := node.(Map); !ok {
+			return fmt.Errorf("%s cannot tag %s", tag, nodeKind(node))
+		}
+		return nil
+	case SeqTag:
+		if _, ok := node.(List); !ok {
+			return fmt.Errorf("%s cannot tag %s", tag, nodeKind(node))
+		}
+		return nil
+	case StrTag:
+	case IntTag, FloatTag, BoolTag, NullTag, TimestampTag:
+		want = tag
+	default:
+		return nil
+	}
+
+	scalar, ok := node.(Scalar)
+	if !ok {
+		return fmt.Errorf("%s cannot tag %s", tag, nodeKind(node))
+	}
+	if want == "" {
+		return nil
+	}
+	got := schema.tag(string(scalar))
+	if got != want && !(want == FloatTag && got == IntTag) {
+		return fmt.Errorf("%q is not a valid %s", string(scalar), tag)
+	}
+	return nil
+}
+
+// applyTag gives the node n describes, which was found at the given column
+// of line, the tag written before it, checks it against the core schema and
+// passes it to the parser's handler for the tag, if any.
+func (p *parser) applyTag(line *indentedLine, col int, tag string, n *note) (*note, error) {
+	if tag == "!!" || tag == "!<>" {
+		return nil, errorAt(line, col, TagError, "missing tag name")
+	}
+
+	if n == nil {
+		n = newNote(Scalar(""), line.pos(col))
+	}
+	n.Tag = tag
+
+	if err := checkTag(tag, n.node, p.schema); err != nil {
+		return nil, errorAt(line, col, TagError, "%s", err)
+	}
+
+	handler := p.tags[tag]
+	if handler == nil {
+		return n, nil
+	}
+	result, err := handler(n.node, &n.NodeInfo)
+	if err != nil {
+		return nil, errorAt(line, col, TagError, "%s: %s", tag, err)
+	}
+	switch {
+	case result == nil:
+		return nil, nil
+	case !sameNode(result, n.node):
+		// The details of the node's entries or items do not describe
+		// those of its replacement.
+		replaced := &note{NodeInfo: n.NodeInfo, node: result}
+		replaced.Keys = nil
+		return replaced, nil
+	}
+	return n, nil
+}
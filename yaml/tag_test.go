@@ -0,0 +1,215 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var tagOfTests = []struct {
+	Value string
+	Tag   string
+}{
+	{"hello", StrTag},
+	{`"42"`, StrTag},
+	{"|\n  42", StrTag},
+	{"42", IntTag},
+	{"-17", IntTag},
+	{"0x1F", IntTag},
+	{"0o755", IntTag},
+	{"0755", IntTag},
+	{"1.5", FloatTag},
+	{"-.5e3", FloatTag},
+	{".inf", FloatTag},
+	{".NaN", FloatTag},
+	{"1.2.3", StrTag},
+	{"true", BoolTag},
+	{"False", BoolTag},
+	{"yes", StrTag},
+	{"~", NullTag},
+	{"null", NullTag},
+	{"''", StrTag},
+	{"{a: 1}", MapTag},
+	{"[1]", SeqTag},
+	{"!!str 42", StrTag},
+	{"!!float 1", FloatTag},
+	{"!secret abc", "!secret"},
+	{"!<tag:yaml.org,2002:int> 7", IntTag},
+	{"!<tag:example.com,2013:x> 7", "!<tag:example.com,2013:x>"},
+}
+
+func TestTagOf(t *testing.T) {
+	for _, test := range tagOfTests {
+		input := "v: " + test.Value + "\n"
+		f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+		if err != nil {
+			t.Errorf("parse(%q): %s", input, err)
+			continue
+		}
+		if got, _ := f.Tag("v"); got != test.Tag {
+			t.Errorf("Tag(%q) = %q, want %q", test.Value, got, test.Tag)
+		}
+	}
+	if got := TagOf(nil); got != "" {
+		t.Errorf("TagOf(nil) = %q, want \"\"", got)
+	}
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	input := "--- !config\n" +
+		"list: !ordered\n" +
+		"  - !!str 42\n" +
+		"  - !pair\n" +
+		"    - a\n" +
+		"    - b\n" +
+		"  - !entry\n" +
+		"    x: 1\n" +
+		"map: !!map\n" +
+		"  mode:   !!str 0755\n" +
+		"  secret: !secret \"p4ss\"\n"
+	f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	tags := map[string]string{
+		"":           "!config",
+		"list":       "!ordered",
+		"list[0]":    StrTag,
+		"list[1]":    "!pair",
+		"list[2]":    "!entry",
+		"map":        MapTag,
+		"map.mode":   StrTag,
+		"map.secret": "!secret",
+		"list[1][0]": "",
+		"list[2].x":  "",
+	}
+	for spec, want := range tags {
+		info, err := f.Info(spec)
+		if err != nil {
+			t.Errorf("Info(%q): %s", spec, err)
+			continue
+		}
+		if got := info.Tag; got != want {
+			t.Errorf("tag of %q = %q, want %q", spec, got, want)
+		}
+	}
+
+	if got, want := f.Render(), input; got != want {
+		t.Errorf("render:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTagStrings(t *testing.T) {
+	f := Config("a: !!str true\n" +
+		"b: !!int '42'\n" +
+		"c: !!float 7\n")
+	if _, err := f.GetBool("a"); err == nil {
+		t.Errorf("GetBool converted a !!str scalar")
+	}
+	if got, err := f.GetInt("b"); err != nil || got != 42 {
+		t.Errorf("GetInt(b) = %d, %v; want 42", got, err)
+	}
+	if got, err := f.Get("c"); err != nil || got != "7" {
+		t.Errorf("Get(c) = %q, %v; want 7", got, err)
+	}
+}
+
+var tagErrorTests = []struct {
+	Input string
+	Err   string
+}{
+	{"a: !!int abc\n", `yaml: 1:4: "abc" is not a valid !!int`},
+	{"a: !!bool yes\n", `yaml: 1:4: "yes" is not a valid !!bool`},
+	{"a: !!map [1]\n", `yaml: 1:4: !!map cannot tag a list`},
+	{"a: !!seq\n  b: 1\n", `yaml: 1:4: !!seq cannot tag a map`},
+	{"a: [!!str {b: 1}]\n", `yaml: 1:5: !!str cannot tag a map`},
+	{"a: !! x\n", `yaml: 1:4: missing tag name`},
+}
+
+func TestTagErrors(t *testing.T) {
+	for _, test := range tagErrorTests {
+		_, err := Parse(bytes.NewBufferString(test.Input))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("parse(%q) error = %#v, want a *ParseError", test.Input, err)
+			continue
+		}
+		if got, want := perr.Error(), test.Err; got != want {
+			t.Errorf("parse(%q) error = %q, want %q", test.Input, got, want)
+		}
+		if got, want := perr.Kind, TagError; got != want {
+			t.Errorf("parse(%q) kind = %s, want %s", test.Input, got, want)
+		}
+	}
+}
+
+func TestTagHandlers(t *testing.T) {
+	p := &Parser{Tags: map[string]TagHandler{
+		"!upper": func(node Node, info *NodeInfo) (Node, error) {
+			s, ok := node.(Scalar)
+			if !ok {
+				return nil, errors.New("not a scalar")
+			}
+			return Scalar(strings.ToUpper(string(s))), nil
+		},
+	}}
+
+	f, err := p.ParseFile(bytes.NewBufferString("a: !upper shout\nb: [!upper x]\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	for spec, want := range map[string]string{"a": "SHOUT", "b[0]": "X"} {
+		if got, err := f.Get(spec); err != nil || got != want {
+			t.Errorf("%s = %q, %v, want %q", spec, got, err, want)
+		}
+		if pos, _ := f.Position(spec); !pos.IsValid() {
+			t.Errorf("%s lost its position", spec)
+		}
+	}
+
+	_, err = p.Parse(bytes.NewBufferString("a: !upper\n  b: 1\n"))
+	if got, want := err.Error(), "yaml: 1:4: !upper: not a scalar"; got != want {
+		t.Errorf("handler error = %q, want %q", got, want)
+	}
+
+	node, err := Parse(bytes.NewBufferString("a: !upper quiet\n"))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if a, _ := Child(node, "a"); a != Scalar("quiet") {
+		t.Errorf("handler of another parser called: a = %q", a)
+	}
+}
+
+func TestTaggedEmptyValue(t *testing.T) {
+	for _, input := range []string{
+		"a: !foo\nb: 1\n",
+		"- !foo\n- x\n",
+		"a: !!str  # empty\n",
+	} {
+		f, err := new(Parser).ParseFile(bytes.NewBufferString(input))
+		if err != nil {
+			t.Errorf("parse(%q): %s", input, err)
+			continue
+		}
+		if got := f.Render(); got != input {
+			t.Errorf("Render(%q) = %q", input, got)
+		}
+	}
+}
@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -27,7 +29,9 @@ type Node interface {
 	write(io.Writer, int, int)
 }
 
-// A Map is a YAML Mapping which maps Strings to Nodes.
+// A Map is a YAML Mapping which maps Strings to Nodes.  Like any Go map, it
+// does not keep its keys in order; the File it was read into records the
+// order in which they were written.
 type Map map[string]Node
 
 // Key returns the value associeted with the key in the map.
@@ -36,41 +40,50 @@ func (node Map) Key(key string) Node {
 }
 
 func (node Map) write(out io.Writer, firstind, nextind int) {
-	indent := bytes.Repeat([]byte{' '}, nextind)
-	ind := firstind
+	newPrinter(out, false).mapping(node, nil, firstind, nextind)
+}
 
-	width := 0
-	scalarkeys := []string{}
-	objectkeys := []string{}
-	for key, value := range node {
-		if _, ok := value.(Scalar); ok {
-			if swid := len(key); swid > width {
-				width = swid
-			}
-			scalarkeys = append(scalarkeys, key)
-			continue
+// orderKeys returns the keys of m: those in keys which m still has, in
+// order, followed by any others, sorted.  If merge is set, the merge key is
+// kept where it appears in keys.
+func orderKeys(m Map, keys []string, merge bool) []string {
+	ordered := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	for _, key := range keys {
+		_, ok := m[key]
+		if (ok || merge && key == mergeKey) && !seen[key] {
+			ordered = append(ordered, key)
+			seen[key] = true
+		}
+	}
+	var added []string
+	for key := range m {
+		if !seen[key] {
+			added = append(added, key)
 		}
-		objectkeys = append(objectkeys, key)
 	}
-	sort.Strings(scalarkeys)
-	sort.Strings(objectkeys)
+	sort.Strings(added)
+	return append(ordered, added...)
+}
 
-	for _, key := range scalarkeys {
-		value := node[key].(Scalar)
-		out.Write(indent[:ind])
-		fmt.Fprintf(out, "%-*s %s\n", width+1, key+":", string(value))
-		ind = nextind
+// keysOf returns the keys of m, which n describes, in the order n records.
+func keysOf(m Map, n *note) []string {
+	if n == nil {
+		return orderKeys(m, nil, false)
 	}
-	for _, key := range objectkeys {
-		out.Write(indent[:ind])
-		if node[key] == nil {
-			fmt.Fprintf(out, "%s: <nil>\n", key)
-			continue
-		}
-		fmt.Fprintf(out, "%s:\n", key)
-		ind = nextind
-		node[key].write(out, ind+2, ind+2)
+	return orderKeys(m, n.Keys, false)
+}
+
+// Keys returns the keys of a Map, sorted, or nil if node is not a Map.  The
+// order in which the keys of a map were written is not kept by the Map, even
+// one returned by Parse; File.Keys returns the keys of a map of a File in
+// that order.
+func Keys(node Node) []string {
+	m, ok := node.(Map)
+	if !ok {
+		return nil
 	}
+	return orderKeys(m, nil, false)
 }
 
 // A List is a YAML Sequence of Nodes.
@@ -90,15 +103,7 @@ func (node List) Item(idx int) Node {
 }
 
 func (node List) write(out io.Writer, firstind, nextind int) {
-	indent := bytes.Repeat([]byte{' '}, nextind)
-	ind := firstind
-
-	for _, value := range node {
-		out.Write(indent[:ind])
-		fmt.Fprint(out, "- ")
-		ind = nextind
-		value.write(out, 0, ind+2)
-	}
+	newPrinter(out, false).list(node, nil, firstind, nextind)
 }
 
 // A Scalar is a YAML Scalar.
@@ -107,14 +112,823 @@ type Scalar string
 // String returns the string represented by this Scalar.
 func (node Scalar) String() string { return string(node) }
 
-func (node Scalar) write(out io.Writer, ind, _ int) {
-	fmt.Fprintf(out, "%s%s\n", strings.Repeat(" ", ind), string(node))
+func (node Scalar) write(out io.Writer, ind, nextind int) {
+	writeScalar(out, ind, nextind, "", plainText(string(node)), PlainStyle, "")
+}
+
+// plainText returns text as it is written for a Scalar which has no note to
+// say how it was written.  It is quoted if it would not be read back as the
+// same text, or if the core schema and YAML11Schema would read it as
+// different values, as they would "yes" or "0755".  Multi-line text is left
+// to be written as a block scalar.
+func plainText(text string) string {
+	if strings.Contains(text, "\n") && !strings.Contains(text, "\r") {
+		return text
+	}
+	if text == "" || needsQuotes(text) || ambiguous(text) {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// ambiguous reports whether the plain scalar text is read as different
+// values by the core schema and by YAML11Schema.
+func ambiguous(text string) bool {
+	kind, value, err := resolve(Scalar(text), nil, CoreSchema)
+	kind11, value11, err11 := resolve(Scalar(text), nil, YAML11Schema)
+	return kind != kind11 || (err == nil) != (err11 == nil) || !reflect.DeepEqual(value, value11)
+}
+
+// writeScalar writes text, which is written after prefix and followed by
+// suffix on its first line.  Text written in LiteralStyle or FoldedStyle, and
+// any multi-line text, is written as a block scalar.
+func writeScalar(out io.Writer, ind, nextind int, prefix, text string, style Style, suffix string) {
+	block := style == LiteralStyle || style == FoldedStyle
+	if !block && !strings.Contains(text, "\n") {
+		fmt.Fprintf(out, "%s%s%s%s\n", strings.Repeat(" ", ind), prefix, text, suffix)
+		return
+	}
+
+	// The lines of a block scalar must be indented past the node that
+	// contains them.
+	if nextind <= ind {
+		nextind = ind + 2
+	}
+	header, lines := blockText(text, style == FoldedStyle)
+	fmt.Fprintf(out, "%s%s%s%s\n", strings.Repeat(" ", ind), prefix, header, suffix)
+	for _, line := range lines {
+		if len(line) == 0 {
+			fmt.Fprintln(out)
+			continue
+		}
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat(" ", nextind), line)
+	}
+}
+
+// A Style describes how a Scalar, or a collection, was written in the
+// source.
+type Style int
+
+const (
+	PlainStyle        Style = iota // unquoted
+	SingleQuotedStyle              // 'quoted'
+	DoubleQuotedStyle              // "quoted"
+	LiteralStyle                   // |
+	FoldedStyle                    // >
+	FlowStyle                      // [a, b] or {a: b}, for a List or a Map
+)
+
+// A Position is a location in a YAML source.
+type Position struct {
+	Filename string // file name, if known
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (in bytes)
+}
+
+// IsValid reports whether the position is known.
+func (pos Position) IsValid() bool { return pos.Line > 0 }
+
+// String returns the position as "file:line:column", or as "line:column"
+// if the file name is not known.
+func (pos Position) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	if pos.Filename != "" {
+		s = pos.Filename + ":" + s
+	}
+	return s
+}
+
+// A NodeInfo holds the details of how a node of a File was written in the
+// source, which the Map, List or Scalar itself does not record.  The parser
+// keeps one for each node it reads, alongside the tree; see File.Info.
+type NodeInfo struct {
+	// Pos is the position at which the node starts: its first key for a
+	// Map, its first '-' for a List, or its first character for a Scalar.
+	Pos Position
+
+	// Style records whether a Scalar was quoted.  A quoted Scalar is
+	// always a string, even if it looks like a number or a boolean.
+	Style Style
+
+	// Comment holds the comment which followed the node on the same line,
+	// including its leading '#'.  The comment after a key which holds a Map
+	// or List belongs to the Map or List.
+	Comment string
+
+	// HeadComment holds the comment lines, and blank lines, before the
+	// entry whose value is the node, and FootComment those after the last
+	// entry of a Map or List.  Each line ends with a newline and does not
+	// include its indentation, which is supplied by Render.
+	HeadComment string
+	FootComment string
+
+	// Anchor is the name the node was given with '&', if any.  Alias is the
+	// name of the anchor an alias refers to, if the node is an alias; it
+	// shares the Map, List or Scalar of the anchored node.
+	Anchor string
+	Alias  string
+
+	// Keys holds the keys of a Map in the order in which they were written,
+	// including a merge key ("<<").  Render writes the keys in this order;
+	// keys which are added to the Map later are written after them.
+	Keys []string
+
+	// Tag is the tag the node was given explicitly, if any, such as "!!str"
+	// or "!secret".  Tags of the core schema are written in their "!!"
+	// shorthand form.  See File.Tag for the tag of a node without one.
+	Tag string
+
+	layout layout
+}
+
+// A layout records the spacing with which a node was written, so that Render
+// can write it the same way.
+type layout struct {
+	valueSpace   int  // spaces between a ':' or '-' and a value on the same line
+	commentSpace int  // spaces before the line comment
+	indent       int  // indentation of a Map or List past the key holding it
+	indented     bool // whether indent is known
+}
+
+// Quoted reports whether the node was written as a quoted Scalar.
+func (info *NodeInfo) Quoted() bool {
+	return info.Style == SingleQuotedStyle || info.Style == DoubleQuotedStyle
+}
+
+// A note holds the NodeInfo of a node of a File, along with the notes of the
+// values of a Map or the items of a List.
+type note struct {
+	NodeInfo
+
+	// node is the node the note describes.  Once it has been replaced in
+	// the tree, the note no longer applies to the node in its place.
+	node Node
+
+	entries   map[string]*note // the notes of a Map's values, by key
+	items     []*note          // the notes of a List's items, in order
+	keyStyles map[string]Style // the style of each quoted key of a Map
+
+	// merged is set for the value of a key which a merge key added to its
+	// Map, and which is still shared with the map it came from.
+	merged bool
+}
+
+// newNote returns a note for node, which starts at pos.
+func newNote(node Node, pos Position) *note {
+	return &note{NodeInfo: NodeInfo{Pos: pos}, node: node}
+}
+
+// value returns the node described by n, or nil if n is nil.
+func (n *note) value() Node {
+	if n == nil {
+		return nil
+	}
+	return n.node
+}
+
+// positionOf returns the position recorded by n, or the zero Position if n is
+// nil.
+func positionOf(n *note) Position {
+	if n == nil {
+		return Position{}
+	}
+	return n.Pos
+}
+
+// of returns n if it describes node, or nil if n is nil or node has taken
+// the place of the node it described.
+func (n *note) of(node Node) *note {
+	if n == nil || !sameNode(n.node, node) {
+		return nil
+	}
+	return n
+}
+
+// entry returns the note of the value of key in m, which n describes.
+func (n *note) entry(m Map, key string) *note {
+	if n == nil {
+		return nil
+	}
+	return n.entries[key].of(m[key])
+}
+
+// item returns the note of the item at idx in list, which n describes.
+func (n *note) item(list List, idx int) *note {
+	if n == nil || idx >= len(n.items) {
+		return nil
+	}
+	return n.items[idx].of(list[idx])
+}
+
+// setEntry records the note of the value of key.
+func (n *note) setEntry(key string, e *note) {
+	if e == nil {
+		delete(n.entries, key)
+		return
+	}
+	if n.entries == nil {
+		n.entries = make(map[string]*note)
+	}
+	n.entries[key] = e
+}
+
+// setKeyStyle records the style in which key was written.
+func (n *note) setKeyStyle(key string, style Style) {
+	if style == PlainStyle {
+		delete(n.keyStyles, key)
+		return
+	}
+	if n.keyStyles == nil {
+		n.keyStyles = make(map[string]Style)
+	}
+	n.keyStyles[key] = style
+}
+
+// keyStyle returns the style in which key of the map n describes was
+// written.
+func (n *note) keyStyle(key string) Style {
+	if n == nil {
+		return PlainStyle
+	}
+	return n.keyStyles[key]
+}
+
+// keyText returns key as it is written in the map n describes: quoted as it
+// was in the source, or otherwise only if it must be.
+func (n *note) keyText(key string) string {
+	if style := n.keyStyle(key); style != PlainStyle {
+		return quote(key, style)
+	}
+	return quoteKey(key)
+}
+
+// setItem records the note of the item at idx.
+func (n *note) setItem(idx int, item *note) {
+	for len(n.items) <= idx {
+		n.items = append(n.items, nil)
+	}
+	n.items[idx] = item
+}
+
+// isShared reports whether the node n describes is shared with another part
+// of the tree, through an alias or a merge key.
+func isShared(n *note) bool {
+	return n != nil && (n.Alias != "" || n.merged)
+}
+
+// detach returns a copy of node, and of n, which describes it, so that a
+// Map or List which is shared through an alias or a merge key can be
+// changed without changing the node it was shared with.
+func detach(node Node, n *note) (Node, *note) {
+	switch v := node.(type) {
+	case Map:
+		m := make(Map, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		node = m
+	case List:
+		node = append(List(nil), v...)
+	}
+	if n == nil {
+		return node, nil
+	}
+	c := *n
+	c.node, c.Anchor, c.Alias, c.merged = node, "", "", false
+	c.Keys = append([]string(nil), n.Keys...)
+	if n.entries != nil {
+		c.entries = make(map[string]*note, len(n.entries))
+		for key, e := range n.entries {
+			c.entries[key] = e
+		}
+	}
+	c.items = append([]*note(nil), n.items...)
+	return node, &c
+}
+
+// setScalar returns a copy of n describing value, which takes the place of
+// the Scalar n describes.  The copy keeps the comments, tag and style of n,
+// unless a plain scalar cannot hold value, in which case it is double-quoted.
+func (n *note) setScalar(value Scalar) *note {
+	set := new(note)
+	if n != nil {
+		*set = *n
+	}
+	set.node, set.Alias, set.merged = value, "", false
+	if set.Style == PlainStyle && needsQuotes(string(value)) {
+		set.Style = DoubleQuotedStyle
+	}
+	return set
+}
+
+// sameNode reports whether a and b are the same node: the same Map or List,
+// or Scalars with the same text.
+func sameNode(a, b Node) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case Scalar:
+		b, ok := b.(Scalar)
+		return ok && a == b
+	case Map:
+		b, ok := b.(Map)
+		return ok && reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+	case List:
+		b, ok := b.(List)
+		return ok && len(a) == 0 && len(b) == 0 || ok && len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
+	}
+	return false
 }
 
 // Render returns a string of the node as a YAML document.  Note that
-// Scalars will have a newline appended if they are rendered directly.
+// Scalars will have a newline appended if they are rendered directly.  The
+// entries of each map with scalar values are written first, followed by the
+// rest, each sorted by key, whatever the order in which they were read; to
+// write a map back in its source order, read it into a File and use
+// File.Render.  Scalars are quoted if they would otherwise be read back as
+// different text, or as different values by the core schema and
+// YAML11Schema.
 func Render(node Node) string {
 	buf := bytes.NewBuffer(nil)
-	node.write(buf, 0, 0)
+	newPrinter(buf, false).document(node, nil)
 	return buf.String()
 }
+
+// RenderSorted is like Render, but it always writes the values of each map
+// aligned and every collection in block style; see File.RenderSorted.
+func RenderSorted(node Node) string {
+	buf := bytes.NewBuffer(nil)
+	newPrinter(buf, true).document(node, nil)
+	return buf.String()
+}
+
+// Render returns the file as a YAML document.  The keys of each map are
+// written in the order in which they were read, and the comments, tags,
+// anchors and aliases of the source are written back, along with the way in
+// which each node was laid out, so that a file which has been read and
+// changed is written with only the changed nodes differing.
+func (f *File) Render() string {
+	buf := bytes.NewBuffer(nil)
+	newPrinter(buf, false).document(f.Root, f.notes.of(f.Root))
+	return buf.String()
+}
+
+// RenderSorted is like Render, but it ignores the order of the keys in the
+// source.  Instead, the entries of each map with scalar values are written
+// first, followed by the rest, each sorted by key.  It also ignores comments,
+// anchors and the layout of the source, writing every collection in block
+// style with the values of each map aligned.  Tags and quoting are kept.
+func (f *File) RenderSorted() string {
+	buf := bytes.NewBuffer(nil)
+	newPrinter(buf, true).document(f.Root, f.notes.of(f.Root))
+	return buf.String()
+}
+
+// A printer writes nodes as YAML, as the notes which describe them say they
+// were written.
+type printer struct {
+	io.Writer
+	sorted  bool            // for RenderSorted
+	anchors map[string]Node // the nodes named by the anchors written so far
+}
+
+func newPrinter(out io.Writer, sorted bool) *printer {
+	return &printer{Writer: out, sorted: sorted, anchors: make(map[string]Node)}
+}
+
+// document writes node, which is described by n, as the root of a document.
+func (p *printer) document(node Node, n *note) {
+	// The properties of the root node can only be written after a document
+	// marker.
+	if props := p.properties(n); props != "" {
+		if p.isInline(node, n) {
+			io.WriteString(p, "--- ")
+		} else {
+			fmt.Fprintf(p, "--- %s\n", props)
+		}
+	}
+	p.value(node, n, 0, 0)
+}
+
+// value writes node, which is described by n.  The tags and comments of maps
+// and lists are written by the node which holds them, on the line before
+// their first entry.
+func (p *printer) value(node Node, n *note, ind, nextind int) {
+	if alias := p.alias(node, n); alias != "" {
+		fmt.Fprintf(p, "%s%s%s\n", strings.Repeat(" ", ind), alias, p.lineComment(n))
+		return
+	}
+	if p.isInline(node, n) {
+		suffix := p.lineComment(n)
+		switch node := node.(type) {
+		case Scalar:
+			prefix := p.properties(n)
+			if prefix != "" && (node != "" || n.Quoted()) {
+				prefix += " "
+			}
+			p.anchor(node, n)
+			switch {
+			case n == nil:
+				writeScalar(p, ind, nextind, prefix, plainText(string(node)), PlainStyle, suffix)
+			case n.Quoted():
+				writeScalar(p, ind, nextind, prefix, quote(string(node), n.Style), PlainStyle, suffix)
+			case node == "" && n.Style != PlainStyle:
+				// An empty block scalar is written as an empty string,
+				// which is not taken to be a null.
+				writeScalar(p, ind, nextind, prefix, `""`, PlainStyle, suffix)
+			default:
+				writeScalar(p, ind, nextind, prefix, string(node), n.Style, suffix)
+			}
+		default:
+			fmt.Fprintf(p, "%s%s%s\n", strings.Repeat(" ", ind), p.flowText(node, n), suffix)
+		}
+		return
+	}
+
+	p.anchor(node, n)
+	switch node := node.(type) {
+	case Map:
+		p.mapping(node, n, ind, nextind)
+	case List:
+		p.list(node, n, ind, nextind)
+	}
+	if !p.sorted && n != nil {
+		writeComments(p, nextind, n.FootComment)
+	}
+}
+
+// mapping writes the entries of m, which is described by n.  Unless the
+// output is for RenderSorted, the keys are written in the order n records;
+// otherwise, or if there is no such order, the entries with scalar values
+// are written before the others, sorted by key.
+func (p *printer) mapping(m Map, n *note, firstind, nextind int) {
+	indent := bytes.Repeat([]byte{' '}, nextind)
+	ind := firstind
+
+	if len(m) == 0 {
+		fmt.Fprintf(p, "%s{}\n", strings.Repeat(" ", firstind))
+		return
+	}
+
+	var keys []string
+	merge := p.merge(m, n)
+	if p.sorted || n == nil || n.Keys == nil {
+		scalarkeys := []string{}
+		objectkeys := []string{}
+		for key, value := range m {
+			if _, ok := value.(Scalar); ok {
+				scalarkeys = append(scalarkeys, key)
+				continue
+			}
+			objectkeys = append(objectkeys, key)
+		}
+		sort.Strings(scalarkeys)
+		sort.Strings(objectkeys)
+		keys = append(scalarkeys, objectkeys...)
+	} else {
+		keys = orderKeys(m, n.Keys, merge != "")
+	}
+
+	// The entries which the merge key supplies are left to it.
+	if merge != "" {
+		written := keys[:0:0]
+		for _, key := range keys {
+			if e := n.entry(m, key); e == nil || !e.merged {
+				written = append(written, key)
+			}
+		}
+		keys = written
+	}
+
+	width := 0
+	for _, key := range keys {
+		if _, ok := m[key]; !ok || p.isInline(m[key], n.entry(m, key)) {
+			if swid := len(n.keyText(key)); swid > width {
+				width = swid
+			}
+		}
+	}
+
+	for _, key := range keys {
+		value, vn := m[key], n.entry(m, key)
+		if _, ok := m[key]; !ok {
+			// The merge key, which holds aliases.
+			vn = n.entries[mergeKey]
+		}
+		if ind == nextind {
+			writeComments(p, ind, p.headComment(vn))
+		}
+		p.Write(indent[:ind])
+		ind = nextind
+		if _, ok := m[key]; !ok {
+			p.key(n.keyText(key), width, vn)
+			fmt.Fprintf(p, "%s%s\n", merge, p.lineComment(vn))
+			continue
+		}
+		switch {
+		case value == nil:
+			// An empty value is read back as null.
+			fmt.Fprintf(p, "%s:%s\n", n.keyText(key), p.lineComment(vn))
+		case p.isInline(value, vn):
+			p.key(n.keyText(key), width, vn)
+			p.value(value, vn, 0, nextind+2)
+		default:
+			fmt.Fprintf(p, "%s:%s%s\n", n.keyText(key), p.tagSuffix(value, vn), p.lineComment(vn))
+			next := ind + 2
+			if l := p.layout(vn); l.indented {
+				next = ind + l.indent
+			}
+			p.value(value, vn, next, next)
+		}
+	}
+}
+
+// key writes a key, as text, whose value, described by n, follows on the
+// same line, spaced as it was in the source or aligned with the other keys
+// of width.
+func (p *printer) key(text string, width int, n *note) {
+	if space := p.layout(n).valueSpace; space > 0 {
+		fmt.Fprintf(p, "%s:%s", text, strings.Repeat(" ", space))
+		return
+	}
+	fmt.Fprintf(p, "%-*s ", width+1, text+":")
+}
+
+// list writes the items of list, which is described by n.
+func (p *printer) list(list List, n *note, firstind, nextind int) {
+	indent := bytes.Repeat([]byte{' '}, nextind)
+	ind := firstind
+
+	if len(list) == 0 {
+		fmt.Fprintf(p, "%s[]\n", strings.Repeat(" ", firstind))
+		return
+	}
+
+	for i, value := range list {
+		vn := n.item(list, i)
+		if ind == nextind {
+			writeComments(p, ind, p.headComment(vn))
+		}
+		p.Write(indent[:ind])
+		ind = nextind
+		if value == nil {
+			fmt.Fprintf(p, "-%s\n", p.lineComment(vn))
+			continue
+		}
+		if p.isInline(value, vn) {
+			space := 1
+			if l := p.layout(vn); l.valueSpace > 0 {
+				space = l.valueSpace
+			}
+			fmt.Fprintf(p, "-%s", strings.Repeat(" ", space))
+			p.value(value, vn, 0, ind+2)
+			continue
+		}
+		tag, comment := p.tagSuffix(value, vn), p.lineComment(vn)
+		if tag != "" || comment != "" {
+			fmt.Fprintf(p, "-%s%s\n", tag, comment)
+			p.value(value, vn, ind+2, ind+2)
+			continue
+		}
+		fmt.Fprint(p, "- ")
+		p.value(value, vn, 0, ind+2)
+	}
+}
+
+// isInline reports whether node, which is described by n, is written on the
+// same line as the key or '-' which holds it: a Scalar, an empty Map or List,
+// which is written as "{}" or "[]", an alias, or a flow collection unless the
+// output is for RenderSorted.
+func (p *printer) isInline(node Node, n *note) bool {
+	switch node := node.(type) {
+	case Scalar:
+		return true
+	case Map:
+		if len(node) == 0 {
+			return true
+		}
+	case List:
+		if len(node) == 0 {
+			return true
+		}
+	}
+	if p.alias(node, n) != "" {
+		return true
+	}
+	return !p.sorted && n != nil && n.Style == FlowStyle
+}
+
+// alias returns the alias which n records for node, such as "*base", if the
+// anchor it names has been written for the same node, or "" if not.
+func (p *printer) alias(node Node, n *note) string {
+	if p.sorted || n == nil || n.Alias == "" {
+		return ""
+	}
+	if target, ok := p.anchors[n.Alias]; !ok || !sameNode(target, node) {
+		return ""
+	}
+	return "*" + n.Alias
+}
+
+// anchor records that the anchor of n, if it has one, names node.
+func (p *printer) anchor(node Node, n *note) {
+	if !p.sorted && n != nil && n.Anchor != "" {
+		p.anchors[n.Anchor] = node
+	}
+}
+
+// merge returns the value of the merge key of m, which is described by n,
+// such as "*base" or "[*a, *b]", or "" if it cannot be written: if the
+// anchors it refers to have not been written for the same maps, if m no
+// longer has every key they would give it, or if they no longer give the
+// entries they gave m to it.
+func (p *printer) merge(m Map, n *note) string {
+	if p.sorted || n == nil || n.entries[mergeKey] == nil {
+		return ""
+	}
+	if _, ok := m[mergeKey]; ok {
+		return ""
+	}
+	mn := n.entries[mergeKey]
+	sources := []*note{mn}
+	if list, ok := mn.node.(List); ok && mn.Alias == "" {
+		sources = sources[:0]
+		for i := range list {
+			sources = append(sources, mn.item(list, i))
+		}
+	}
+
+	var aliases []string
+	merged := make(map[string]bool)
+	for _, src := range sources {
+		from, ok := src.value().(Map)
+		alias := p.alias(from, src)
+		if !ok || alias == "" {
+			return ""
+		}
+		for key := range from {
+			if _, ok := m[key]; !ok {
+				return ""
+			}
+			// The first map to give a key gave m its entry.
+			if e := n.entry(m, key); !merged[key] && e != nil && e.merged && !sameNode(m[key], from[key]) {
+				return ""
+			}
+			merged[key] = true
+		}
+		aliases = append(aliases, alias)
+	}
+	if len(sources) == 1 && mn.Alias != "" {
+		return aliases[0]
+	}
+	return "[" + strings.Join(aliases, ", ") + "]"
+}
+
+// properties returns the anchor and tag of n, as they are written before the
+// node, or "" if it has neither.  Anchors are not written for RenderSorted.
+func (p *printer) properties(n *note) string {
+	if n == nil {
+		return ""
+	}
+	var props []string
+	if n.Anchor != "" && !p.sorted {
+		props = append(props, "&"+n.Anchor)
+	}
+	if n.Tag != "" {
+		props = append(props, n.Tag)
+	}
+	return strings.Join(props, " ")
+}
+
+// tagSuffix returns the properties of a Map or List written in block style,
+// preceded by a space, or "" if it has none.
+func (p *printer) tagSuffix(node Node, n *note) string {
+	props := p.properties(n)
+	if props == "" || p.isInline(node, n) {
+		return ""
+	}
+	return " " + props
+}
+
+// layout returns the layout recorded by n, or the zero layout if there is no
+// note or the output is for RenderSorted.
+func (p *printer) layout(n *note) layout {
+	if p.sorted || n == nil {
+		return layout{}
+	}
+	return n.layout
+}
+
+// lineComment returns the comment recorded by n, preceded by spaces, or "" if
+// it has none or the output is for RenderSorted.
+func (p *printer) lineComment(n *note) string {
+	if p.sorted || n == nil || n.Comment == "" {
+		return ""
+	}
+	space := n.layout.commentSpace
+	if space < 1 {
+		space = 1
+	}
+	// Comments gathered from several lines of a flow collection are
+	// written on one.
+	comment := strings.Replace(n.Comment, "\n", " ", -1)
+	return strings.Repeat(" ", space) + comment
+}
+
+// headComment returns the head comment recorded by n, or "" if the output is
+// for RenderSorted.
+func (p *printer) headComment(n *note) string {
+	if p.sorted || n == nil {
+		return ""
+	}
+	return n.HeadComment
+}
+
+// writeComments writes the lines of a head or foot comment with the given
+// indentation.  Blank lines are written without any.
+func writeComments(out io.Writer, ind int, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			fmt.Fprintln(out)
+			continue
+		}
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat(" ", ind), line)
+	}
+}
+
+// flowText returns node, which is described by n, written in flow style.
+func (p *printer) flowText(node Node, n *note) string {
+	if alias := p.alias(node, n); alias != "" {
+		return alias
+	}
+	p.anchor(node, n)
+
+	var text string
+	switch v := node.(type) {
+	case Map:
+		var keys []string
+		merge := p.merge(v, n)
+		if n != nil {
+			keys = orderKeys(v, n.Keys, merge != "")
+		} else {
+			keys = orderKeys(v, nil, false)
+		}
+		entries := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if _, ok := v[key]; !ok {
+				entries = append(entries, mergeKey+": "+merge)
+				continue
+			}
+			vn := n.entry(v, key)
+			if merge != "" && vn != nil && vn.merged {
+				continue
+			}
+			entry := flowScalar(key, n.keyStyle(key), true) + ":"
+			if value := p.flowText(v[key], vn); value != "" {
+				entry += " " + value
+			}
+			entries = append(entries, entry)
+		}
+		text = "{" + strings.Join(entries, ", ") + "}"
+	case List:
+		items := make([]string, 0, len(v))
+		for i, item := range v {
+			items = append(items, p.flowText(item, n.item(v, i)))
+		}
+		text = "[" + strings.Join(items, ", ") + "]"
+	case Scalar:
+		if n == nil {
+			text = flowScalar(plainText(string(v)), PlainStyle, false)
+			break
+		}
+		text = flowScalar(string(v), n.Style, false)
+	}
+	if props := p.properties(n); props != "" {
+		text = strings.TrimSpace(props + " " + text)
+	}
+	return text
+}
+
+// flowScalar returns text as a scalar inside a flow collection, quoting it if
+// it was quoted or if it would not be read back as the same text.
+func flowScalar(text string, style Style, key bool) string {
+	switch {
+	case style == SingleQuotedStyle || style == DoubleQuotedStyle:
+		return quote(text, style)
+	case text == "" && !key:
+		return ""
+	case strings.ContainsAny(text, ",[]{}"):
+		return strconv.Quote(text)
+	case key:
+		return quoteKey(text)
+	case needsQuotes(text) || strings.Contains(text, "\n"):
+		return strconv.Quote(text)
+	}
+	return text
+}
@@ -15,6 +15,8 @@
 package yaml
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -115,6 +117,26 @@ question:
 yahoo:
   company: Yahoo! Inc.
   url:     http://yahoo.com/
+`,
+	},
+	{
+		Tree: Map{
+			"motd": Scalar("Welcome!\n\n  Be nice.\n"),
+			"tail": Scalar("no newline\nat the end"),
+			"list": List{Scalar("one\ntwo\n\n")},
+		},
+		Expect: `motd: |
+  Welcome!
+
+    Be nice.
+tail: |-
+  no newline
+  at the end
+list:
+  - |+
+    one
+    two
+
 `,
 	},
 }
@@ -126,3 +148,77 @@ func TestRender(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyOrder(t *testing.T) {
+	f := Config("zebra: 1\n" +
+		"apple:\n" +
+		"  - x\n" +
+		"mango: 3\n" +
+		"kiwi: {b: 1, a: 2}\n")
+
+	if got, want := Keys(f.Root), []string{"apple", "kiwi", "mango", "zebra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys = %q, want %q", got, want)
+	}
+	if got, _ := f.Keys(""); !reflect.DeepEqual(got, []string{"zebra", "apple", "mango", "kiwi"}) {
+		t.Errorf("File.Keys = %q, want the source order", got)
+	}
+
+	m := f.Root.(Map)
+	delete(m, "apple")
+	m["banana"] = Scalar("5")
+	m["avocado"] = Scalar("6")
+	m["zebra"] = Scalar("7")
+	if got, _ := f.Keys(""); !reflect.DeepEqual(got, []string{"zebra", "mango", "kiwi", "avocado", "banana"}) {
+		t.Errorf("File.Keys after mutation = %q", got)
+	}
+
+	if got, want := f.Render(), ""+
+		"zebra:   7\n"+
+		"mango: 3\n"+
+		"kiwi: {b: 1, a: 2}\n"+
+		"avocado: 6\n"+
+		"banana:  5\n"; got != want {
+		t.Errorf("Render:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := f.RenderSorted(), ""+
+		"avocado: 6\n"+
+		"banana:  5\n"+
+		"mango:   3\n"+
+		"zebra:   7\n"+
+		"kiwi:\n"+
+		"  a: 2\n"+
+		"  b: 1\n"; got != want {
+		t.Errorf("RenderSorted:\n%s\nwant:\n%s", got, want)
+	}
+
+	empty := Config("b: {}\na: []\n").Root
+	if got, want := RenderSorted(empty), "a: []\nb: {}\n"; got != want {
+		t.Errorf("RenderSorted of empty collections:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := RenderSorted(Map{"a": List{}, "b": Map{}}), "a: []\nb: {}\n"; got != want {
+		t.Errorf("RenderSorted of an empty Map and List:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Only a File keeps the order; the Map returned by Parse does not.
+	const src = "zebra: 1\napple: 2\n"
+	node, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+	if got, want := Keys(node), []string{"apple", "zebra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys(Parse) = %q, want %q", got, want)
+	}
+	if got, want := Render(node), "apple: 2\nzebra: 1\n"; got != want {
+		t.Errorf("Render(Parse):\n%s\nwant:\n%s", got, want)
+	}
+	if got := Config(src).Render(); got != src {
+		t.Errorf("File.Render:\n%s\nwant:\n%s", got, src)
+	}
+
+	if got, want := Keys(Map{"b": nil, "a": nil}), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys of a plain Map = %q, want %q", got, want)
+	}
+	if got := Keys(List{}); got != nil {
+		t.Errorf("Keys of a List = %q, want nil", got)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"errors"
+	"reflect"
+)
+
+var (
+	// SkipChildren may be returned by a WalkFunc or a TransformFunc to
+	// skip the nodes beneath the one it was called with.
+	SkipChildren = errors.New("yaml: skip children")
+
+	// StopWalk may be returned by a WalkFunc or a TransformFunc to end the
+	// walk early.  Walk and Transform do not return it as an error.
+	StopWalk = errors.New("yaml: stop walk")
+)
+
+// A WalkFunc is called by Walk with each node and its path, as accepted by
+// Child.  The value of an empty key or list item is nil.
+type WalkFunc func(path string, node Node) error
+
+// Walk calls fn with root, whose path is "", and with every node beneath it.
+// A node comes before the nodes beneath it, the items of a List are visited
+// in order, and the values of a Map in the order given by Keys, so the order
+// does not change from one walk to the next.  A node which is shared through
+// an alias is visited at each place it appears.
+//
+// If fn returns SkipChildren, the nodes beneath its node are skipped; if it
+// returns StopWalk, Walk returns nil at once.  Any other error ends the walk
+// and is returned by Walk.
+func Walk(root Node, fn WalkFunc) error {
+	if err := walk("", root, nil, fn); err != StopWalk {
+		return err
+	}
+	return nil
+}
+
+// Walk is like the Walk function, for the nodes of the file, but it visits
+// the values of each Map in the order in which their keys were written.
+func (f *File) Walk(fn WalkFunc) error {
+	if err := walk("", f.Root, f.notes.of(f.Root), fn); err != StopWalk {
+		return err
+	}
+	return nil
+}
+
+// walk calls fn with node, which is described by n, and the nodes beneath it.
+func walk(path string, node Node, n *note, fn WalkFunc) error {
+	if err := fn(path, node); err == SkipChildren {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch v := node.(type) {
+	case Map:
+		for _, key := range keysOf(v, n) {
+			if err := walk(keyPath(path, key), v[key], n.entry(v, key), fn); err != nil {
+				return err
+			}
+		}
+	case List:
+		for i, item := range v {
+			if err := walk(indexPath(path, i), item, n.item(v, i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A TransformFunc is called by Transform with each node and its path, and
+// returns the node to put in its place.
+type TransformFunc func(path string, node Node) (Node, error)
+
+// Transform calls fn with root and every node beneath it, in the order used
+// by Walk, and puts the node returned by fn in place of each.  The nodes
+// beneath the returned node are visited next, so fn sees the nodes of any
+// Map or List it returns rather than those it replaced.  Transform returns
+// the new root.
+//
+// The Maps and Lists of the tree are changed in place.  A Map or List which
+// is shared through an alias or a merge key is passed to fn at each place it
+// appears, but the nodes beneath it are visited only the first time, so that
+// they are changed once and remain shared.  SkipChildren and StopWalk are
+// handled as by Walk, and the node returned with them is kept.
+// If fn returns any other error, Transform returns it with the root as it
+// stands, with the changes made so far.
+func Transform(root Node, fn TransformFunc) (Node, error) {
+	root, _, err := transform("", root, nil, make(visited), fn)
+	if err == StopWalk {
+		err = nil
+	}
+	return root, err
+}
+
+// Transform is like the Transform function, for the nodes of the file, but
+// it visits the values of each Map in the order in which their keys were
+// written.  The root of the file is replaced by the one fn returns.  A Scalar
+// which fn replaces with another keeps its comments, tag and style, as with
+// SetScalar; any other node which fn replaces loses the details of how the
+// node it replaced was written.
+func (f *File) Transform(fn TransformFunc) error {
+	root, n, err := transform("", f.Root, f.notes.of(f.Root), make(visited), fn)
+	f.Root, f.notes = root, n
+	if err == StopWalk {
+		err = nil
+	}
+	return err
+}
+
+// A visited set holds the Maps and Lists whose nodes Transform has visited.
+type visited map[interface{}]bool
+
+// add records that the nodes beneath node are being visited, and reports
+// whether they have not been before.  A Scalar or an empty Map or List has
+// no nodes beneath it to visit again.
+func (seen visited) add(node Node) bool {
+	var id interface{}
+	switch v := node.(type) {
+	case Map:
+		if len(v) == 0 {
+			return true
+		}
+		id = reflect.ValueOf(v).Pointer()
+	case List:
+		if len(v) == 0 {
+			return true
+		}
+		id = &v[0]
+	default:
+		return true
+	}
+	if seen[id] {
+		return false
+	}
+	seen[id] = true
+	return true
+}
+
+// transform calls fn with node, which is described by n, and the nodes
+// beneath it which have not been seen, and returns the node fn puts in its
+// place and its note.
+func transform(path string, node Node, n *note, seen visited, fn TransformFunc) (Node, *note, error) {
+	replaced, err := fn(path, node)
+	old, wasScalar := node.(Scalar)
+	if s, ok := replaced.(Scalar); ok && wasScalar && s != old && n != nil {
+		n = n.setScalar(s)
+	}
+	node, n = replaced, n.of(replaced)
+	if err == SkipChildren {
+		return node, n, nil
+	} else if err != nil {
+		return node, n, err
+	}
+	if !seen.add(node) {
+		return node, n, nil
+	}
+
+	switch v := node.(type) {
+	case Map:
+		for _, key := range keysOf(v, n) {
+			en := n.entry(v, key)
+			child, cn, err := transform(keyPath(path, key), v[key], en, seen, fn)
+			if _, ok := child.(Scalar); ok && cn != nil && en != nil && en.merged {
+				// A Scalar which a merge key gave the map is written
+				// by it while it matches the Scalar it came from.
+				cn.merged = true
+			}
+			v[key] = child
+			if n != nil {
+				n.setEntry(key, cn)
+			}
+			if err != nil {
+				return node, n, err
+			}
+		}
+	case List:
+		for i, item := range v {
+			child, cn, err := transform(indexPath(path, i), item, n.item(v, i), seen, fn)
+			v[i] = child
+			if n != nil {
+				n.setItem(i, cn)
+			}
+			if err != nil {
+				return node, n, err
+			}
+		}
+	}
+	return node, n, nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2013 Google, Inc.  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const walkInput = `name: web
+servers:
+  - host: a
+    port: 80
+  - host: b
+a.b: dotted
+`
+
+func TestWalk(t *testing.T) {
+	f := Config(walkInput)
+	stop := errors.New("stop")
+
+	tests := []struct {
+		Desc string
+		File *File
+		Stop string // the path at which fn returns Err
+		Err  error
+		Want []string
+		Fail error
+	}{
+		{
+			Desc: "whole tree",
+			File: f,
+			Want: []string{"", "name", "servers", "servers[0]", "servers[0].host", "servers[0].port",
+				"servers[1]", "servers[1].host", `["a.b"]`},
+		},
+		{
+			Desc: "skip children",
+			File: f,
+			Stop: "servers[0]",
+			Err:  SkipChildren,
+			Want: []string{"", "name", "servers", "servers[0]", "servers[1]", "servers[1].host", `["a.b"]`},
+		},
+		{
+			Desc: "stop",
+			File: f,
+			Stop: "servers[0].host",
+			Err:  StopWalk,
+			Want: []string{"", "name", "servers", "servers[0]", "servers[0].host"},
+		},
+		{
+			Desc: "error",
+			File: f,
+			Stop: "servers",
+			Err:  stop,
+			Want: []string{"", "name", "servers"},
+			Fail: stop,
+		},
+		{
+			Desc: "built map is sorted",
+			File: &File{Root: Map{"b": List{Scalar("x")}, "a": Scalar("y"), "c": nil}},
+			Want: []string{"", "a", "b", "b[0]", "c"},
+		},
+		{
+			Desc: "nil",
+			File: &File{},
+			Want: []string{""},
+		},
+	}
+
+	for _, test := range tests {
+		var got []string
+		err := test.File.Walk(func(path string, node Node) error {
+			got = append(got, path)
+			if path == test.Stop && test.Err != nil {
+				return test.Err
+			}
+			return nil
+		})
+		if err != test.Fail {
+			t.Errorf("%s: Walk error = %v, want %v", test.Desc, err, test.Fail)
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("%s: Walk visited\n got %q\nwant %q", test.Desc, got, test.Want)
+		}
+	}
+
+	// Each path finds its node.
+	Walk(f.Root, func(path string, node Node) error {
+		if got, err := Child(f.Root, path); err != nil || !sameNode(got, node) {
+			t.Errorf("Child(%q) = %v, %v, want the node walked", path, got, err)
+		}
+		return nil
+	})
+}
+
+func TestTransform(t *testing.T) {
+	upper := func(path string, node Node) (Node, error) {
+		if s, ok := node.(Scalar); ok {
+			return Scalar(strings.ToUpper(string(s))), nil
+		}
+		return node, nil
+	}
+
+	tests := []struct {
+		Desc   string
+		Fn     TransformFunc
+		Output string
+	}{
+		{
+			Desc: "change scalars in place",
+			Fn:   upper,
+			Output: "name: WEB\n" +
+				"servers:\n" +
+				"  - host: A\n" +
+				"    port: 80\n" +
+				"  - host: B\n" +
+				"a.b: DOTTED\n",
+		},
+		{
+			Desc: "replace a subtree, then visit it",
+			Fn: func(path string, node Node) (Node, error) {
+				if path == "servers" {
+					return List{Scalar("x"), Scalar("y")}, nil
+				}
+				return upper(path, node)
+			},
+			Output: "name: WEB\n" +
+				"servers:\n" +
+				"  - X\n" +
+				"  - \"Y\"\n" +
+				"a.b: DOTTED\n",
+		},
+		{
+			Desc: "replace a value and skip",
+			Fn: func(path string, node Node) (Node, error) {
+				if path == "name" {
+					return Map{"x": Scalar("y")}, SkipChildren
+				}
+				if path == "servers" {
+					return node, SkipChildren
+				}
+				return upper(path, node)
+			},
+			Output: "name:\n" +
+				"  x: \"y\"\n" +
+				"servers:\n" +
+				"  - host: a\n" +
+				"    port: 80\n" +
+				"  - host: b\n" +
+				"a.b: DOTTED\n",
+		},
+		{
+			Desc: "stop",
+			Fn: func(path string, node Node) (Node, error) {
+				if path == "servers[1]" {
+					return Scalar("c"), StopWalk
+				}
+				return upper(path, node)
+			},
+			Output: "name: WEB\n" +
+				"servers:\n" +
+				"  - host: A\n" +
+				"    port: 80\n" +
+				"  - c\n" +
+				"a.b: dotted\n",
+		},
+	}
+
+	for _, test := range tests {
+		f := Config(walkInput)
+		if err := f.Transform(test.Fn); err != nil {
+			t.Errorf("%s: Transform: %s", test.Desc, err)
+			continue
+		}
+		if got := f.Render(); got != test.Output {
+			t.Errorf("%s:\n got %q\nwant %q", test.Desc, got, test.Output)
+		}
+	}
+
+	fail := errors.New("fail")
+	root, err := Transform(Scalar("a"), func(path string, node Node) (Node, error) {
+		return Scalar("b"), fail
+	})
+	if err != fail || root != Scalar("b") {
+		t.Errorf("Transform = %v, %v, want b, %v", root, err, fail)
+	}
+}
+
+func TestTransformShared(t *testing.T) {
+	const input = "base: &b\n" +
+		"  x: 1\n" +
+		"  inner: {y: 2}\n" +
+		"prod: *b\n" +
+		"dev:\n" +
+		"  <<: *b\n" +
+		"  z: 3\n"
+	const output = "base: &b\n" +
+		"  x: 1!\n" +
+		"  inner: {y: 2!}\n" +
+		"prod: *b\n" +
+		"dev:\n" +
+		"  <<: *b\n" +
+		"  z: 3!\n"
+	bang := func(path string, node Node) (Node, error) {
+		if s, ok := node.(Scalar); ok {
+			return s + "!", nil
+		}
+		return node, nil
+	}
+
+	f := Config(input)
+	if err := f.Transform(bang); err != nil {
+		t.Fatalf("File.Transform: %s", err)
+	}
+	if got := f.Render(); got != output {
+		t.Errorf("File.Transform:\n got %q\nwant %q", got, output)
+	}
+
+	root, err := Transform(Config(input).Root, bang)
+	if err != nil {
+		t.Fatalf("Transform: %s", err)
+	}
+	if got, want := root, Config(output).Root; !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform = %#v, want %#v", got, want)
+	}
+}